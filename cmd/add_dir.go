@@ -22,11 +22,6 @@ func runAddDir(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	cfg, err := readConf()
-	if err != nil {
-		return err
-	}
-
 	if len(args) == 0 {
 		wd, err := os.Getwd()
 		if err != nil {
@@ -35,35 +30,52 @@ func runAddDir(cmd *cobra.Command, args []string) error {
 		args = append(args, wd)
 	}
 
-	seen := make(map[string]bool)
-	for _, dir := range cfg.RepoPaths() {
-		seen[dir] = true
-	}
+	group := viper.GetString("set-group")
+	tags := viper.GetStringSlice("add-tag")
 
 	lw := logwriter.NewColorWriter("")
 	defer lw.Flush()
 	client := git.NewClient(nil)
-	for _, arg := range args {
-		wt, err := client.WorkTree(arg)
-		if err != nil {
-			fmt.Fprintf(lw, "[warn] error adding %s: %s", arg, err)
-			continue
-		}
-		if seen[wt.Repo] {
-			continue
+	var results []Result
+	if err := updateConf(func(cfg *config.Config) error {
+		for _, arg := range args {
+			wt, err := client.WorkTree(arg)
+			if err != nil {
+				fmt.Fprintf(lw, "[warn] error adding %s: %s", arg, err)
+				results = append(results, newResult(arg, err))
+				continue
+			}
+			results = append(results, newResult(wt.Repo, nil))
+			repo := cfg.Find(wt.Repo)
+			if repo == nil {
+				repo = &config.Repo{
+					Path: wt.Repo,
+				}
+				cfg.Repos = append(cfg.Repos, repo)
+				if dryRun() {
+					fmt.Fprintf(lw, "would add %s\n", wt.Repo)
+				}
+			}
+			if len(repo.URL) == 0 {
+				repo.URL, _ = client.RemoteURL(wt.Repo, "origin")
+			}
+			if len(group) > 0 {
+				repo.Group = group
+			}
+			repo.AddTags(tags...)
 		}
-		seen[wt.Repo] = true
-		cfg.Repos = append(cfg.Repos, &config.Repo{
-			Path: wt.Repo,
-		})
+		return nil
+	}); err != nil {
+		return err
 	}
-
-	return writeConf(cfg)
+	return printResults(results)
 }
 
 func init() {
 	c := addDirCmd
-	//flags := c.Flags()
+	flags := c.Flags()
 
 	RootCmd.AddCommand(c)
+	flags.String("set-group", "", "Group to place repos in")
+	flags.StringSlice("add-tag", nil, "Tags to add to repos")
 }
@@ -23,8 +23,8 @@ var addrCmd = &cobra.Command{
 }
 
 type Port struct {
-	Port  int
-	Proto string
+	Port  int    `json:"port"`
+	Proto string `json:"proto"`
 }
 
 func (a Port) String() string {
@@ -63,12 +63,12 @@ func (a Address) Convert() MarshalAddress {
 
 // Stable version of Address suitable for marhsalling/output
 type MarshalAddress struct {
-	IP          string
-	Port        int
-	Proto       string
-	ServiceName string
-	ServicePort Port
-	GuessedHost bool
+	IP          string `json:"ip"`
+	Port        int    `json:"port"`
+	Proto       string `json:"proto"`
+	ServiceName string `json:"service_name"`
+	ServicePort Port   `json:"service_port"`
+	GuessedHost bool   `json:"guessed_host"`
 }
 
 func (a MarshalAddress) String() string {
@@ -254,6 +254,6 @@ func init() {
 
 	RootCmd.AddCommand(c)
 	flags.String("container", "", "If multiple ports match, return the one the named container")
-	flags.String("format", "text", "Output format {json,text,yaml}")
+	flags.String("format", "text", "Output format {json,yaml,text,ndjson}")
 	flags.String("filter", "", "Filter in the syntax of the go package template")
 }
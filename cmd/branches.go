@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddn0/peanut/config"
+	"github.com/ddn0/peanut/git"
+	"github.com/dustin/go-humanize"
+	"github.com/mattn/go-colorable"
+	"github.com/mgutz/ansi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var branchesCmd = &cobra.Command{
+	Use:   "branches",
+	Short: "list and delete local branches",
+	Long: `List the local branches of each repo with the state of their upstream
+branch, the age of their last commit and whether they are merged in the remote
+mainline branch.
+
+With --delete-gone, --delete-merged or --delete-stale, delete the matching
+branches after confirmation. The checked out branch and the mainline branch are
+never deleted. Stale branches and branches merged in the mainline branch are
+deleted with "git branch -d", which refuses to delete unmerged work. Branches
+whose upstream is gone or whose changes were squash-merged are deleted with
+"git branch -D".`,
+	RunE: runBranches,
+}
+
+// A BranchStatus is a local branch of a repo.
+type BranchStatus struct {
+	Repo string `json:"repo"`
+	git.Branch
+	Mainline string `json:"mainline"` // Mainline branch name
+	Merged   bool   `json:"merged"`   // Merged in the remote mainline branch
+	Squashed bool   `json:"squashed"` // Merged by squash-merging or rebasing rather than as an ancestor
+	Stale    bool   `json:"stale"`    // Last commit is older than --stale
+	Deleted  bool   `json:"deleted"`
+}
+
+// track returns a brief description of the state of the upstream branch.
+func (a BranchStatus) track() string {
+	switch {
+	case a.Gone:
+		return "gone"
+	case len(a.Upstream) == 0:
+		return "no upstream"
+	case a.Ahead > 0 && a.Behind > 0:
+		return fmt.Sprintf("ahead %d, behind %d", a.Ahead, a.Behind)
+	case a.Ahead > 0:
+		return fmt.Sprintf("ahead %d", a.Ahead)
+	case a.Behind > 0:
+		return fmt.Sprintf("behind %d", a.Behind)
+	default:
+		return "up to date"
+	}
+}
+
+// deleteReason returns why the branch should be deleted according to the
+// delete flags or "" if it should be kept.
+func (a BranchStatus) deleteReason() string {
+	if a.Head || a.Name == a.Mainline {
+		return ""
+	}
+	switch {
+	case a.Gone && viper.GetBool("delete-gone"):
+		return "gone"
+	case a.Squashed && viper.GetBool("delete-merged"):
+		return "squash-merged"
+	case a.Merged && viper.GetBool("delete-merged"):
+		return "merged"
+	case a.Stale && viper.GetBool("delete-stale"):
+		return "stale"
+	default:
+		return ""
+	}
+}
+
+// deleteFlag returns the flag of git branch to delete the branch for reason.
+// Only branches known to be gone or squash-merged are force-deleted.
+func deleteFlag(reason string) string {
+	if reason == "gone" || reason == "squash-merged" {
+		return "-D"
+	}
+	return "-d"
+}
+
+func readBranches(gc *git.Client, repo *config.Repo) ([]BranchStatus, error) {
+	dir := repo.Path
+	branches, err := gc.Branches(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	mainBranch := mainline(gc, repo, dir)
+	root := "origin/" + mainBranch
+	if _, err := readGitCommand(dir, "rev-parse", "--verify", root); err != nil {
+		root = mainBranch
+	}
+	merged := make(map[string]bool)
+	squashed := make(map[string]bool)
+	if names, sq, err := mergedBranches(ioutil.Discard, dir, root); err == nil {
+		for _, n := range names {
+			merged[n] = true
+		}
+		for _, n := range sq {
+			merged[n] = true
+			squashed[n] = true
+		}
+	}
+
+	staleBefore := time.Now().Add(-viper.GetDuration("stale"))
+	var ret []BranchStatus
+	for _, b := range branches {
+		ret = append(ret, BranchStatus{
+			Repo:     dir,
+			Branch:   b,
+			Mainline: mainBranch,
+			Merged:   merged[b.Name],
+			Squashed: squashed[b.Name],
+			Stale:    b.CommitDate.Before(staleBefore),
+		})
+	}
+	return ret, nil
+}
+
+// confirm asks the user a yes or no question on stderr and returns true if
+// they answer yes.
+func confirm(question string) bool {
+	fmt.Fprintf(stderr, "%s [y/N] ", question)
+	line, _ := bufio.NewReader(stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+func prettyBranches(branches []BranchStatus) {
+	out := colorable.NewColorableStdout()
+	var width int
+	for _, b := range branches {
+		if n := len(b.Name); n > width {
+			width = n
+		}
+	}
+
+	var repo string
+	for _, b := range branches {
+		if b.Repo != repo {
+			repo = b.Repo
+			fmt.Fprintln(out, ansi.Color(repo, "cyan"))
+		}
+
+		head := " "
+		if b.Head {
+			head = "*"
+		}
+		trackColor := "yellow"
+		switch {
+		case b.Gone:
+			trackColor = "red"
+		case len(b.Upstream) > 0 && b.Ahead == 0 && b.Behind == 0:
+			trackColor = "green"
+		}
+		var notes []string
+		if b.Merged {
+			notes = append(notes, ansi.Color("merged", "blue"))
+		}
+		if b.Stale {
+			notes = append(notes, ansi.Color("stale", "yellow"))
+		}
+		if reason := b.deleteReason(); len(reason) > 0 {
+			notes = append(notes, ansi.Color(fmt.Sprintf("delete (%s)", reason), "red"))
+		}
+		fmt.Fprintf(out, "  %s %-*s %s %s %s\n",
+			head,
+			width, b.Name,
+			ansi.Color(fmt.Sprintf("%-18s", b.track()), trackColor),
+			humanize.Time(b.CommitDate),
+			strings.Join(notes, " "))
+	}
+}
+
+func runBranches(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	cfg, err := readConf()
+	if err != nil {
+		return err
+	}
+
+	gc := git.NewClient(nil)
+
+	selected, err := selectRepos(cmd.Context(), cfg)
+	if err != nil {
+		return err
+	}
+
+	repos := make(map[string]*config.Repo)
+	var dirs []string
+	for _, repo := range selected {
+		if _, ok := repos[repo.Path]; ok {
+			continue
+		}
+		repos[repo.Path] = repo
+		dirs = append(dirs, repo.Path)
+	}
+
+	var lock sync.Mutex
+	var branches []BranchStatus
+	results, _ := doAllRepos(cmd.Context(), dirs, false, func(ctx context.Context, dir string) error {
+		bs, err := readBranches(gc, repos[dir])
+		if err != nil {
+			fmt.Fprintf(stderr, "warn: error reading branches of %q: %s\n", dir, err)
+			return err
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		branches = append(branches, bs...)
+		return nil
+	})
+	sort.Slice(branches, func(i, j int) bool {
+		if branches[i].Repo != branches[j].Repo {
+			return branches[i].Repo < branches[j].Repo
+		}
+		return branches[i].Name < branches[j].Name
+	})
+
+	failed := make(map[string]bool)
+	for _, r := range results {
+		if !r.Ok {
+			failed[r.Repo] = true
+		}
+	}
+
+	format := viper.GetString("format")
+	if isPretty(format) {
+		prettyBranches(branches)
+	}
+
+	var doomed []int
+	for idx, b := range branches {
+		if len(b.deleteReason()) > 0 {
+			doomed = append(doomed, idx)
+		}
+	}
+	if len(doomed) > 0 && !dryRun() && !viper.GetBool("yes") {
+		for _, idx := range doomed {
+			b := branches[idx]
+			fmt.Fprintf(stderr, "  %s %s (%s)\n", b.Repo, b.Name, b.deleteReason())
+		}
+		if !confirm(fmt.Sprintf("delete %d branches?", len(doomed))) {
+			doomed = nil
+		}
+	}
+	for _, idx := range doomed {
+		b := &branches[idx]
+		if err := execGitCommand(cmd.Context(), b.Repo, "branch", deleteFlag(b.deleteReason()), b.Name); err != nil {
+			fmt.Fprintf(stderr, "warn: error deleting %s in %q: %s\n", b.Name, b.Repo, err)
+			failed[b.Repo] = true
+			continue
+		}
+		b.Deleted = !dryRun()
+	}
+
+	if !isPretty(format) {
+		if err := print(branches, format, viper.GetString("filter")); err != nil {
+			return err
+		}
+	}
+	return failedError(len(failed), len(dirs))
+}
+
+func init() {
+	c := branchesCmd
+	flags := c.Flags()
+
+	RootCmd.AddCommand(c)
+	flags.Duration("stale", 90*24*time.Hour, "Consider branches stale if their last commit is older than this")
+	flags.Bool("delete-gone", false, "Delete branches whose upstream branch no longer exists")
+	flags.Bool("delete-merged", false, "Delete branches merged in the remote mainline branch")
+	flags.Bool("delete-stale", false, "Delete stale branches")
+	flags.Bool("yes", false, "Delete branches without confirmation")
+}
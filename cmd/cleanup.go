@@ -30,20 +30,32 @@ func runCleanup(cmd *cobra.Command, args []string) error {
 		defer lw.Flush()
 		opt := cleanupOpt{
 			RemoveRunning: viper.GetBool("remove-running"),
+			DryRun:        dryRun(),
 		}
 		res, err := cleanup(opt)
+		if format := viper.GetString("format"); !isPretty(format) {
+			if err := print(res, format, viper.GetString("filter")); err != nil {
+				done <- err
+				return
+			}
+		} else {
+			verb := "Removed"
+			if opt.DryRun {
+				verb = "Would remove"
+			}
+			for _, v := range res.Volumes {
+				fmt.Println(verb, "volume ", v.Name)
+			}
+			for _, c := range res.Containers {
+				fmt.Println(verb, "container ", c.ID)
+			}
+			for _, i := range res.Images {
+				fmt.Println(verb, "image ", i.ID)
+			}
+		}
 		if err != nil {
 			done <- err
 		}
-		for _, v := range res.Volumes {
-			fmt.Println("Removed volume ", v.Name)
-		}
-		for _, c := range res.Containers {
-			fmt.Println("Removed container ", c.ID)
-		}
-		for _, i := range res.Images {
-			fmt.Println("Removed image ", i.ID)
-		}
 	}()
 
 	select {
@@ -56,12 +68,13 @@ func runCleanup(cmd *cobra.Command, args []string) error {
 
 type cleanupOpt struct {
 	RemoveRunning bool
+	DryRun        bool // Return what would be removed without removing it
 }
 
 type cleanupResults struct {
-	Volumes    []docker.Volume
-	Images     []docker.APIImages
-	Containers []*docker.Container
+	Volumes    []docker.Volume     `json:"volumes"`
+	Images     []docker.APIImages  `json:"images"`
+	Containers []*docker.Container `json:"containers"`
 }
 
 func cleanup(opt cleanupOpt) (*cleanupResults, error) {
@@ -69,19 +82,20 @@ func cleanup(opt cleanupOpt) (*cleanupResults, error) {
 
 	cs, e := removeContainers(removeContainersOpt{
 		RemoveRunning: opt.RemoveRunning,
+		DryRun:        opt.DryRun,
 	})
 	if e != nil {
 		err = e
 	}
 
-	vols, e := removeDanglingVolumes()
+	vols, e := removeDanglingVolumes(opt.DryRun)
 	if e != nil {
 		err = e
 	}
 
 	var images []docker.APIImages
 
-	is1, e := removeDanglingImages()
+	is1, e := removeDanglingImages(opt.DryRun)
 	if e != nil {
 		err = e
 	}
@@ -95,10 +109,11 @@ func cleanup(opt cleanupOpt) (*cleanupResults, error) {
 }
 
 // Remove dangling volumes. These are volumes not referenced by any running
-// containers. Return volumes removed (even if there was an error).
+// containers. Return volumes removed (even if there was an error), or that
+// would be removed if dry is set.
 //
 // Warning: this will remove any unreferenced data containers.
-func removeDanglingVolumes() ([]docker.Volume, error) {
+func removeDanglingVolumes(dry bool) ([]docker.Volume, error) {
 	var removed []docker.Volume
 
 	client, err := docker.NewClientFromEnv()
@@ -115,15 +130,17 @@ func removeDanglingVolumes() ([]docker.Volume, error) {
 		return removed, err
 	}
 	for _, vol := range vols {
-		if err := client.RemoveVolume(vol.Name); err != nil {
-			return removed, err
+		if !dry {
+			if err := client.RemoveVolume(vol.Name); err != nil {
+				return removed, err
+			}
 		}
 		removed = append(removed, vol)
 	}
 	return removed, nil
 }
 
-func removeImage(filters map[string][]string, keepTags []string) ([]docker.APIImages, error) {
+func removeImage(filters map[string][]string, keepTags []string, dry bool) ([]docker.APIImages, error) {
 	keep := make(map[string]bool)
 	for _, t := range keepTags {
 		keep[t] = true
@@ -162,8 +179,10 @@ func removeImage(filters map[string][]string, keepTags []string) ([]docker.APIIm
 				continue
 			}
 
-			if err := client.RemoveImageExtended(img.ID, docker.RemoveImageOptions{Force: true}); err != nil {
-				return removed, err
+			if !dry {
+				if err := client.RemoveImageExtended(img.ID, docker.RemoveImageOptions{Force: true}); err != nil {
+					return removed, err
+				}
 			}
 			removed = append(removed, img)
 			seen[img.ID] = true
@@ -177,15 +196,16 @@ func removeImage(filters map[string][]string, keepTags []string) ([]docker.APIIm
 }
 
 // Remove dangling images. These are images that have been superseded by new
-// versions. Return images removed.
-func removeDanglingImages() ([]docker.APIImages, error) {
+// versions. Return images removed, or that would be removed if dry is set.
+func removeDanglingImages(dry bool) ([]docker.APIImages, error) {
 	return removeImage(map[string][]string{
 		"dangling": []string{"true"},
-	}, nil)
+	}, nil, dry)
 }
 
 type removeContainersOpt struct {
 	RemoveRunning bool // Remove running containers too
+	DryRun        bool // Return containers to remove without removing them
 }
 
 // Remove containers. Return containers removed.
@@ -208,6 +228,8 @@ func removeContainers(opt removeContainersOpt) ([]*docker.Container, error) {
 			continue
 		} else if !opt.RemoveRunning && (c.State.Running || c.State.Paused) {
 			continue
+		} else if opt.DryRun {
+			removed = append(removed, c)
 		} else if e := client.RemoveContainer(docker.RemoveContainerOptions{ID: c.ID, Force: true}); e != nil {
 			err = e
 		} else {
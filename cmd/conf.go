@@ -1,33 +1,51 @@
 package cmd
 
 import (
-	"io/ioutil"
+	"os"
 
 	"github.com/ddn0/peanut/config"
-	"github.com/ghodss/yaml"
 	"github.com/spf13/viper"
 )
 
-func readConf() (*config.Config, error) {
-	dirFile := viper.GetString("dir")
-	bs, err := ioutil.ReadFile(dirFile)
-	if err != nil {
-		return &config.Config{}, nil
-	}
+// dirFileSet returns true if the dir file is set by flag, environment or
+// config file.
+func dirFileSet() bool {
+	f := RootCmd.PersistentFlags().Lookup("dir")
+	return f.Changed || viper.InConfig("dir") || len(os.Getenv("PEANUT_DIR")) > 0
+}
 
-	var cfg config.Config
-	if err := yaml.Unmarshal(bs, &cfg); err != nil {
-		return nil, err
+// dirFile returns the dir file to use. In order of precedence, this is the dir
+// file set by flag, environment or config file, the nearest .peanut.yaml in
+// the current directory or its parents, and finally the default dir file.
+func dirFile() string {
+	if dirFileSet() {
+		return viper.GetString("dir")
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if fn := config.FindProjectFile(wd); len(fn) > 0 {
+			return fn
+		}
 	}
-	return &cfg, nil
+	return viper.GetString("dir")
 }
 
-func writeConf(cfg *config.Config) error {
-	dirFile := viper.GetString("dir")
-	bs, err := yaml.Marshal(cfg)
-	if err != nil {
-		return err
+// readConf reads the dir file and the files it includes. It is an error if the
+// dir file was set but does not exist. Otherwise, a missing dir file is an
+// empty config since no repos have been added yet.
+func readConf() (*config.Config, error) {
+	fn := dirFile()
+	if _, err := os.Stat(fn); os.IsNotExist(err) && !dirFileSet() {
+		return &config.Config{Version: config.CurrentVersion}, nil
 	}
+	return config.LoadLayered(fn)
+}
 
-	return ioutil.WriteFile(dirFile, bs, 0666)
+// updateConf reads the dir file, calls fn to modify it and writes the result
+// while holding a lock on the dir file. The dir file is created if it does not
+// exist. In dry-run mode, the result is not written.
+func updateConf(fn func(*config.Config) error) error {
+	if dryRun() {
+		return config.DryUpdate(dirFile(), fn)
+	}
+	return config.Update(dirFile(), fn)
 }
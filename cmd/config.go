@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ddn0/peanut/config"
+	"github.com/mattn/go-colorable"
+	"github.com/mgutz/ansi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "manage the dir file",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "check the dir file and the files it includes for errors",
+	Long: `Check the dir file and the files it includes for syntax errors, unknown
+keys, values of the wrong type, repos without a path, duplicate repos and
+unsupported versions.
+
+Files written by older versions of peanut are migrated to the current version
+when read and saved at the current version the next time peanut changes them.`,
+	RunE: runConfigValidate,
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	file := dirFile()
+	err := config.ValidateLayered(file)
+	problems := config.ValidationErrors{}
+	if !errors.As(err, &problems) && err != nil {
+		return err
+	}
+
+	if format := viper.GetString("format"); !isPretty(format) {
+		if err := print(problems, format, viper.GetString("filter")); err != nil {
+			return err
+		}
+	} else {
+		out := colorable.NewColorableStdout()
+		if len(problems) == 0 {
+			fmt.Fprintf(out, "%s is valid\n", file)
+		}
+		for _, p := range problems {
+			fmt.Fprintln(out, ansi.Color(p.Error(), "red"))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+	return nil
+}
+
+func init() {
+	c := configCmd
+
+	RootCmd.AddCommand(c)
+	c.AddCommand(configValidateCmd)
+}
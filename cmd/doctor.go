@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ddn0/peanut/config"
+	"github.com/ddn0/peanut/git"
+	"github.com/mattn/go-colorable"
+	"github.com/mgutz/ansi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "check directories in config",
+	Long: `Check the dir file for paths that no longer exist, paths that are not
+git repos, entries that resolve to the same repo (e.g., through symlinks) and
+repos nested in other repos.
+
+With --prune, remove missing paths, non-git paths and duplicates, and replace
+paths inside a repo with the top level of the repo. Nested repos are only
+reported.`,
+	RunE: runDoctor,
+}
+
+// Problems found by doctor
+const (
+	ProblemMissing     = "missing"      // Path does not exist
+	ProblemNotGit      = "not-git"      // Path is not in a git repo
+	ProblemNotTopLevel = "not-toplevel" // Path is inside a repo rather than its top level
+	ProblemDuplicate   = "duplicate"    // Path is the same repo as another entry
+	ProblemNested      = "nested"       // Repo is inside another registered repo
+)
+
+// A DoctorResult is a problem with an entry in the dir file.
+type DoctorResult struct {
+	Repo    string `json:"repo"`
+	Problem string `json:"problem"`
+	Detail  string `json:"detail,omitempty"` // Other repo or path involved in the problem, if any
+	Fixed   bool   `json:"fixed"`            // Repaired by --prune
+}
+
+// diagnose returns the problems with the repos in cfg and the repos that
+// remain after fixing them.
+func diagnose(cfg *config.Config) ([]DoctorResult, []*config.Repo) {
+	gc := git.NewClient(nil)
+	var results []DoctorResult
+	var kept []*config.Repo
+	owner := make(map[string]*config.Repo)
+	for _, repo := range cfg.Repos {
+		if _, err := os.Stat(repo.Path); os.IsNotExist(err) {
+			results = append(results, DoctorResult{Repo: repo.Path, Problem: ProblemMissing})
+			continue
+		}
+		top, err := gc.TopLevel(repo.Path)
+		if err != nil {
+			results = append(results, DoctorResult{Repo: repo.Path, Problem: ProblemNotGit})
+			continue
+		}
+		if o := owner[top]; o != nil {
+			results = append(results, DoctorResult{Repo: repo.Path, Problem: ProblemDuplicate, Detail: o.Path})
+			if len(o.Group) == 0 {
+				o.Group = repo.Group
+			}
+			o.AddTags(repo.Tags...)
+			continue
+		}
+		if top != repo.Path {
+			results = append(results, DoctorResult{Repo: repo.Path, Problem: ProblemNotTopLevel, Detail: top})
+			fixed := *repo
+			fixed.Path = top
+			repo = &fixed
+		}
+		owner[top] = repo
+		kept = append(kept, repo)
+	}
+
+	var tops []string
+	for top := range owner {
+		tops = append(tops, top)
+	}
+	sort.Strings(tops)
+	for _, inner := range tops {
+		for _, outer := range tops {
+			if inner != outer && underRoot(inner, outer) {
+				results = append(results, DoctorResult{Repo: inner, Problem: ProblemNested, Detail: outer})
+				break
+			}
+		}
+	}
+	return results, kept
+}
+
+func prettyDoctorResults(results []DoctorResult) {
+	out := colorable.NewColorableStdout()
+	if len(results) == 0 {
+		fmt.Fprintln(out, "no problems found")
+		return
+	}
+
+	for _, r := range results {
+		var detail string
+		switch r.Problem {
+		case ProblemDuplicate:
+			detail = "same repo as " + r.Detail
+		case ProblemNotTopLevel:
+			detail = "top level is " + r.Detail
+		case ProblemNested:
+			detail = "inside " + r.Detail
+		}
+		var fix string
+		switch {
+		case r.Fixed:
+			fix = ansi.Color("fixed", "green")
+		case dryRun() && r.Problem != ProblemNested && viper.GetBool("prune"):
+			fix = ansi.Color("would fix", "yellow")
+		}
+		fmt.Fprintf(out, "%s %s %s %s\n",
+			ansi.Color(fmt.Sprintf("%-12s", r.Problem), "red"),
+			ansi.Color(r.Repo, "cyan"),
+			detail,
+			fix)
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	var results []DoctorResult
+	var total int
+	check := func(cfg *config.Config) error {
+		var kept []*config.Repo
+		total = len(cfg.Repos)
+		results, kept = diagnose(cfg)
+		cfg.Repos = kept
+		return nil
+	}
+
+	if viper.GetBool("prune") {
+		if err := updateConf(check); err != nil {
+			return err
+		}
+		if !dryRun() {
+			for idx := range results {
+				results[idx].Fixed = results[idx].Problem != ProblemNested
+			}
+		}
+	} else {
+		cfg, err := readConf()
+		if err != nil {
+			return err
+		}
+		if err := check(cfg); err != nil {
+			return err
+		}
+	}
+
+	if format := viper.GetString("format"); isPretty(format) {
+		prettyDoctorResults(results)
+	} else if err := print(results, format, viper.GetString("filter")); err != nil {
+		return err
+	}
+
+	var unfixed int
+	for _, r := range results {
+		if !r.Fixed && r.Problem != ProblemNested {
+			unfixed += 1
+		}
+	}
+	return failedError(unfixed, total)
+}
+
+func init() {
+	c := doctorCmd
+	flags := c.Flags()
+
+	RootCmd.AddCommand(c)
+	flags.Bool("prune", false, "Repair the dir file")
+}
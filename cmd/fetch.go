@@ -9,7 +9,6 @@ import (
 
 	"github.com/ddn0/peanut/git"
 	"github.com/ddn0/peanut/logwriter"
-	"github.com/ddn0/peanut/pdo"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -20,37 +19,16 @@ var fetchCmd = &cobra.Command{
 	RunE:  runFetch,
 }
 
-func fetch(ctx context.Context, item interface{}) error {
-	dir := item.(string)
-
-	lw := logwriter.NewColorWriter(filepath.Base(dir))
-	defer lw.Flush()
-	cmd := exec.Command("git", "fetch", "--all", "--prune")
-	cmd.Dir = dir
-	cmd.Stdout = lw
-	cmd.Stderr = lw
-
-	defer func() {
-		if cmd.Process != nil {
-			cmd.Process.Kill()
-		}
-	}()
-
+func fetch(ctx context.Context, dir string) error {
 	if viper.GetBool("verbose") {
+		lw := logwriter.NewColorWriter(filepath.Base(dir))
 		fmt.Fprintln(lw, "fetching")
+		lw.Flush()
 	}
 
-	errs := make(chan error, 1)
-	go func() {
-		errs <- cmd.Run()
-	}()
-
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case err := <-errs:
-		return err
-	}
+	cmd := exec.Command("git", "fetch", "--all", "--prune")
+	cmd.Dir = dir
+	return runInRepo(ctx, dir, cmd)
 }
 
 func runFetch(cmd *cobra.Command, args []string) error {
@@ -65,13 +43,22 @@ func runFetch(cmd *cobra.Command, args []string) error {
 
 	gc := git.NewClient(nil)
 
+	paths, err := selectRepoPaths(cmd.Context(), cfg)
+	if err != nil {
+		return err
+	}
+
+	// Report repos that cannot be read, e.g., because they no longer exist,
+	// rather than skip fetching the others
 	seen := make(map[string]bool)
-	var dirs []interface{}
-	for _, dir := range cfg.RepoPaths() {
+	var dirs []string
+	var unreadable []Result
+	for _, dir := range paths {
 		wt, err := gc.WorkTree(dir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "warn: error reading git work tree of %q: %s\n", dir, err)
-			return err
+			unreadable = append(unreadable, newResult(dir, err))
+			continue
 		}
 		if seen[wt.Repo] {
 			continue
@@ -80,20 +67,26 @@ func runFetch(cmd *cobra.Command, args []string) error {
 		dirs = append(dirs, wt.Repo)
 	}
 
-	if err := pdo.DoAll(pdo.DoAllOpt{
-		Func:          fetch,
-		Items:         dirs,
-		Timeout:       viper.GetDuration("timeout"),
-		MaxConcurrent: viper.GetInt("max-concurrent"),
-	}); err != nil {
-		return err
+	if viper.GetBool("events") {
+		if err := enableEvents(); err != nil {
+			return err
+		}
+	}
+
+	stopDashboard := startDashboard(dirs)
+	results, err := doAllRepos(cmd.Context(), dirs, false, fetch)
+	stopDashboard()
+	if perr := printResults(append(results, unreadable...)); perr != nil {
+		return perr
 	}
-	return nil
+	return err
 }
 
 func init() {
 	c := fetchCmd
-	//flags := c.Flags()
+	flags := c.Flags()
 
 	RootCmd.AddCommand(c)
+	flags.Bool("dashboard", false, "Show the progress of each repo on a live dashboard when stdout is a terminal and the format is pretty")
+	flags.Bool("events", false, "Write process output and exit status as newline-delimited JSON events to stdout")
 }
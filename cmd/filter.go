@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/ddn0/peanut/git"
+	"github.com/spf13/viper"
+)
+
+// A repoFilter selects repos based on their git state.
+type repoFilter struct {
+	dirty        bool
+	behind       bool
+	branch       string   // Glob for current branch
+	changedSince string   // Commit HEAD must differ from
+	changedPaths []string // Limit changedSince to these paths
+	where        *template.Template
+}
+
+// newRepoFilter returns the filter given by the flags or nil if there is no
+// filter.
+func newRepoFilter() (*repoFilter, error) {
+	f := &repoFilter{
+		dirty:        viper.GetBool("dirty"),
+		behind:       viper.GetBool("behind"),
+		branch:       viper.GetString("branch"),
+		changedSince: viper.GetString("changed-since"),
+		changedPaths: viper.GetStringSlice("changed-paths"),
+	}
+	if _, err := path.Match(f.branch, ""); err != nil {
+		return nil, fmt.Errorf("bad branch pattern %q: %s", f.branch, err)
+	}
+	if w := viper.GetString("where"); len(w) > 0 {
+		t, err := template.New("where").Parse(w)
+		if err != nil {
+			return nil, err
+		}
+		f.where = t
+	}
+	if !f.dirty && !f.behind && len(f.branch) == 0 && len(f.changedSince) == 0 && f.where == nil {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Match returns true if the repo with status s passes the filter.
+func (a *repoFilter) Match(s *Status) (bool, error) {
+	if a.dirty && !s.Dirty {
+		return false, nil
+	}
+	if a.behind && s.Behind == 0 {
+		return false, nil
+	}
+	if len(a.branch) > 0 {
+		if ok, _ := path.Match(a.branch, s.Commit.Branch); !ok {
+			return false, nil
+		}
+	}
+	if len(a.changedSince) > 0 {
+		changed, err := git.NewClient(nil).ChangedSince(s.Repo, a.changedSince, a.changedPaths...)
+		if err != nil {
+			return false, fmt.Errorf("error comparing with %q: %s", a.changedSince, err)
+		}
+		if !changed {
+			return false, nil
+		}
+	}
+	if a.where != nil {
+		var buf bytes.Buffer
+		if err := a.where.Execute(&buf, s); err != nil {
+			return false, err
+		}
+		if strings.TrimSpace(buf.String()) != "true" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
 
-	"github.com/ddn0/peanut/logwriter"
-	"github.com/ddn0/peanut/pdo"
+	"github.com/ddn0/peanut/config"
+	"github.com/ddn0/peanut/git"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -14,36 +20,114 @@ import (
 var foreachCmd = &cobra.Command{
 	Use:   "foreach [args] [--] <command>",
 	Short: "execute a command in each directory",
-	RunE:  runForeach,
+	Long: `Execute a command in each directory.
+
+The command can find out about the repo it runs in through the environment
+variables PEANUT_REPO, PEANUT_REPO_NAME, PEANUT_BRANCH, PEANUT_SHA,
+PEANUT_INDEX, PEANUT_GROUP and PEANUT_TAGS. With --template, arguments are
+also expanded as go templates with the fields of RepoInfo, e.g., {{.Name}} or
+{{.Branch}}.
+
+With --shell, a single argument is run by sh -c as is, so it may contain
+pipes and redirections. Multiple arguments are quoted before being joined.`,
+	RunE: runForeach,
 }
 
-func spawn(ctx context.Context, item interface{}, args []string) error {
-	dir := item.(string)
+// RepoInfo describes the repo a foreach command runs in.
+type RepoInfo struct {
+	Repo   string   // Path to repo
+	Name   string   // Base name of Repo
+	Index  int      // Position of repo in the list of selected repos
+	Branch string   // Current branch
+	Sha    string   // Current commit sha
+	Group  string   // Group from dir file
+	Tags   []string // Tags from dir file
+	Commit *git.Commit
+}
 
-	lw := logwriter.NewColorWriter(filepath.Base(dir))
-	defer lw.Flush()
-	cmd := exec.Command(args[0], args[1:]...)
-	cmd.Dir = dir
-	cmd.Stdout = lw
-	cmd.Stderr = lw
+func newRepoInfo(repo *config.Repo, index int) (*RepoInfo, error) {
+	commit, err := git.NewClient(nil).Head(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &RepoInfo{
+		Repo:   repo.Path,
+		Name:   filepath.Base(repo.Path),
+		Index:  index,
+		Branch: commit.Branch,
+		Sha:    commit.Sha,
+		Group:  repo.Group,
+		Tags:   repo.Tags,
+		Commit: commit,
+	}, nil
+}
 
-	defer func() {
-		if cmd.Process != nil {
-			cmd.Process.Kill()
+// Env returns the environment variables describing the repo.
+func (a RepoInfo) Env() []string {
+	return []string{
+		"PEANUT_REPO=" + a.Repo,
+		"PEANUT_REPO_NAME=" + a.Name,
+		"PEANUT_BRANCH=" + a.Branch,
+		"PEANUT_SHA=" + a.Sha,
+		"PEANUT_INDEX=" + strconv.Itoa(a.Index),
+		"PEANUT_GROUP=" + a.Group,
+		"PEANUT_TAGS=" + strings.Join(a.Tags, ","),
+	}
+}
+
+// expandArgs expands each arg as a go template with info.
+func expandArgs(args []string, info *RepoInfo) ([]string, error) {
+	var ret []string
+	for _, arg := range args {
+		t, err := template.New("").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %q: %s", arg, err)
 		}
-	}()
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, info); err != nil {
+			return nil, fmt.Errorf("error expanding %q: %s", arg, err)
+		}
+		ret = append(ret, buf.String())
+	}
+	return ret, nil
+}
+
+// shellQuote quotes arg so that sh treats it as a single word.
+func shellQuote(arg string) string {
+	if len(arg) > 0 && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.,/:=@%+") == "" {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
 
-	errs := make(chan error, 1)
-	go func() {
-		errs <- cmd.Run()
-	}()
+// shellCommand returns the arguments to run args with sh -c. A single
+// argument is a shell command itself; multiple arguments are words.
+func shellCommand(args []string) []string {
+	if len(args) == 1 {
+		return []string{"sh", "-c", args[0]}
+	}
+	var words []string
+	for _, arg := range args {
+		words = append(words, shellQuote(arg))
+	}
+	return []string{"sh", "-c", strings.Join(words, " ")}
+}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case err := <-errs:
-		return err
+func spawn(ctx context.Context, info *RepoInfo, args []string) error {
+	if viper.GetBool("template") {
+		var err error
+		if args, err = expandArgs(args, info); err != nil {
+			return notStarted(info.Repo, err)
+		}
 	}
+	if viper.GetBool("shell") {
+		args = shellCommand(args)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = info.Repo
+	cmd.Env = append(os.Environ(), info.Env()...)
+	return runInRepo(ctx, info.Repo, cmd)
 }
 
 func runForeach(cmd *cobra.Command, args []string) error {
@@ -60,29 +144,51 @@ func runForeach(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	var dirs []interface{}
-	for _, dir := range cfg.RepoPaths() {
-		dirs = append(dirs, dir)
+	if viper.GetBool("events") {
+		if err := enableEvents(); err != nil {
+			return err
+		}
 	}
 
-	if err := pdo.DoAll(pdo.DoAllOpt{
-		Func: func(ctx context.Context, item interface{}) error {
-			return spawn(ctx, item, args)
-		},
-		Items:         dirs,
-		Timeout:       viper.GetDuration("timeout"),
-		MaxConcurrent: viper.GetInt("max-concurrent"),
-	}); err != nil {
+	selected, err := selectRepos(cmd.Context(), cfg)
+	if err != nil {
 		return err
 	}
-	return nil
 
-	return writeConf(cfg)
+	repos := make(map[string]*config.Repo)
+	index := make(map[string]int)
+	var dirs []string
+	for idx, repo := range selected {
+		repos[repo.Path] = repo
+		index[repo.Path] = idx
+		dirs = append(dirs, repo.Path)
+	}
+
+	stopDashboard := startDashboard(dirs)
+	results, err := doAllRepos(cmd.Context(), dirs, viper.GetBool("fail-fast"), func(ctx context.Context, dir string) error {
+		info, err := newRepoInfo(repos[dir], index[dir])
+		if err != nil {
+			return notStarted(dir, err)
+		}
+		return spawn(ctx, info, args)
+	})
+	stopDashboard()
+	if perr := printResults(results); perr != nil {
+		return perr
+	}
+	return err
 }
 
 func init() {
 	c := foreachCmd
-	//flags := c.Flags()
+	flags := c.Flags()
 
 	RootCmd.AddCommand(c)
+	flags.Bool("fail-fast", false, "Stop all commands when any command fails")
+	flags.Bool("dashboard", false, "Show the progress of each repo on a live dashboard when stdout is a terminal and the format is pretty")
+	flags.Bool("events", false, "Write process output and exit status as newline-delimited JSON events to stdout")
+	flags.Bool("shell", false, "Run arguments with sh -c")
+	flags.Bool("template", false, "Expand arguments as go templates with the fields of RepoInfo")
+	flags.Bool("group-output", false, "Print the output of each command at once when it exits")
+	flags.String("output-dir", "", "Also save the output of each command to a file in this directory")
 }
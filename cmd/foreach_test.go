@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestExpandArgs(t *testing.T) {
+	info := &RepoInfo{
+		Repo:   "/src/peanut",
+		Name:   "peanut",
+		Index:  2,
+		Branch: "master",
+	}
+	found, err := expandArgs([]string{"echo", "{{.Name}}-{{.Index}}", "{{.Branch}}"}, info)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"echo", "peanut-2", "master"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %q but found %q", expected, found)
+	}
+
+	if _, err := expandArgs([]string{"{{.Name"}, info); err == nil {
+		t.Errorf("expected error parsing unterminated template")
+	}
+	if _, err := expandArgs([]string{"{{.Missing}}"}, info); err == nil {
+		t.Errorf("expected error expanding unknown field")
+	}
+}
+
+func TestRepoInfoEnv(t *testing.T) {
+	info := RepoInfo{
+		Repo:   "/src/peanut",
+		Name:   "peanut",
+		Index:  1,
+		Branch: "master",
+		Sha:    "abc123",
+		Group:  "tools",
+		Tags:   []string{"go", "cli"},
+	}
+	expected := []string{
+		"PEANUT_REPO=/src/peanut",
+		"PEANUT_REPO_NAME=peanut",
+		"PEANUT_BRANCH=master",
+		"PEANUT_SHA=abc123",
+		"PEANUT_INDEX=1",
+		"PEANUT_GROUP=tools",
+		"PEANUT_TAGS=go,cli",
+	}
+	if found := info.Env(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %q but found %q", expected, found)
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"echo a | tr a b"}, "b\n"},
+		{[]string{"echo", "a  b"}, "a  b\n"},
+		{[]string{"echo", "it's", "$HOME", ""}, "it's $HOME \n"},
+	} {
+		args := shellCommand(tc.args)
+		bs, err := exec.Command(args[0], args[1:]...).Output()
+		if err != nil {
+			t.Fatalf("unexpected error running %q: %s", args, err)
+		}
+		if string(bs) != tc.expected {
+			t.Errorf("%q: expected %q but found %q", tc.args, tc.expected, string(bs))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-colorable"
+	"github.com/mgutz/ansi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list directories in config",
+	RunE:  runList,
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	cfg, err := readConf()
+	if err != nil {
+		return err
+	}
+
+	repos, err := selectRepos(cmd.Context(), cfg)
+	if err != nil {
+		return err
+	}
+
+	if format := viper.GetString("format"); !isPretty(format) {
+		return print(repos, format, viper.GetString("filter"))
+	}
+
+	out := colorable.NewColorableStdout()
+	for _, r := range repos {
+		var labels []string
+		if len(r.Group) > 0 {
+			labels = append(labels, ansi.Color(r.Group, "170"))
+		}
+		for _, t := range r.Tags {
+			labels = append(labels, ansi.Color(t, "blue"))
+		}
+		fmt.Fprintln(out, ansi.Color(r.Path, "cyan"), strings.Join(labels, " "))
+	}
+	return nil
+}
+
+func init() {
+	c := listCmd
+
+	RootCmd.AddCommand(c)
+}
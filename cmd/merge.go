@@ -2,15 +2,24 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
 
+	"github.com/ddn0/peanut/config"
 	"github.com/ddn0/peanut/git"
 	"github.com/ddn0/peanut/logwriter"
+	"github.com/ddn0/peanut/pdo"
+	"github.com/mattn/go-colorable"
+	"github.com/mgutz/ansi"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -20,7 +29,7 @@ var mergeCmd = &cobra.Command{
 	RunE:  runMerge,
 }
 
-func pruneLocal(dir string, roots []string) error {
+func pruneLocal(ctx context.Context, dir string, roots []string) error {
 	lw := logwriter.NewColorWriter(filepath.Base(dir))
 	defer lw.Flush()
 
@@ -37,14 +46,19 @@ func pruneLocal(dir string, roots []string) error {
 	}
 
 	branches := make(map[string]int)
+	squashed := make(map[string]bool)
 	for _, r := range rs {
-		bs, err := mergedBranches(lw, dir, r)
+		merged, sq, err := mergedBranches(lw, dir, r)
 		if err != nil {
 			return err
 		}
-		for _, b := range bs {
+		for _, b := range merged {
 			branches[b] += 1
 		}
+		for _, b := range sq {
+			branches[b] += 1
+			squashed[b] = true
+		}
 	}
 	// Do not process roots
 	for _, r := range rs {
@@ -56,7 +70,13 @@ func pruneLocal(dir string, roots []string) error {
 		if c != len(rs) {
 			continue
 		}
-		if err := execGitCommand(dir, "branch", "-d", b); err != nil {
+		// Squash-merged branches are not ancestors of the roots, so -d
+		// would refuse to delete them
+		flag := "-d"
+		if squashed[b] {
+			flag = "-D"
+		}
+		if err := execGitCommand(ctx, dir, "branch", flag, b); err != nil {
 			return err
 		}
 	}
@@ -84,45 +104,54 @@ func isAncestor(dir, ref1, ref2 string) (bool, error) {
 	return false, err
 }
 
-// Return dir to root if curBranch has been merged in remote root
-func returnMerged(dir string, curBranch, root string) error {
+// Return dir to root if curBranch has been merged in remote root. Returns
+// true if root was checked out.
+func returnMerged(ctx context.Context, dir string, curBranch, root string) (bool, error) {
 	if curBranch == root {
-		return nil
+		return false, nil
 	}
 
 	upstream, err := readGitCommand(dir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", root+"@{upstream}")
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	merged, err := isAncestor(dir, curBranch, upstream)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if !merged {
-		return nil
+		return false, nil
 	}
 
-	if err := execGitCommand(dir, "checkout", root); err != nil {
-		return err
+	if err := execGitCommand(ctx, dir, "checkout", root); err != nil {
+		return false, err
 	}
 
-	lw := logwriter.NewColorWriter(filepath.Base(dir))
-	defer lw.Flush()
+	if !dryRun() {
+		lw := logwriter.NewColorWriter(filepath.Base(dir))
+		defer lw.Flush()
 
-	lw.Printf("cd %s && git checkout %s\n", dir, root)
-	return nil
+		lw.Printf("cd %s && git checkout %s\n", dir, root)
+	}
+	return true, nil
 }
 
-func execGitCommand(dir string, args ...string) error {
+// execGitCommand runs git in dir. In dry-run mode, it only prints the command.
+// If ctx is done before git exits, git is stopped (see runCmd).
+func execGitCommand(ctx context.Context, dir string, args ...string) error {
 	lw := logwriter.NewColorWriter(filepath.Base(dir))
 	defer lw.Flush()
+	if dryRun() {
+		lw.Printf("would run: git %s\n", strings.Join(args, " "))
+		return nil
+	}
 	cmd := exec.Command("git", args...)
 	cmd.Dir = dir
 	cmd.Stdout = lw
 	cmd.Stderr = lw
 
-	return cmd.Run()
+	return runCmd(ctx, cmd)
 }
 
 func readGitCommand(dir string, args ...string) (string, error) {
@@ -159,8 +188,30 @@ func execBranchCommand(out io.Writer, dir string, args ...string) ([]string, err
 	return branches, nil
 }
 
-func mergedBranches(out io.Writer, dir, root string) ([]string, error) {
-	return execBranchCommand(out, dir, "--merged", root)
+// mergedBranches returns the branches merged in root and the branches whose
+// changes are in root without being merged because they were squash-merged or
+// rebased.
+func mergedBranches(out io.Writer, dir, root string) (merged, squashed []string, err error) {
+	merged, err = execBranchCommand(out, dir, "--merged", root)
+	if err != nil {
+		return nil, nil, err
+	}
+	unmerged, err := execBranchCommand(out, dir, "--no-merged", root)
+	if err != nil {
+		return nil, nil, err
+	}
+	gc := git.NewClient(nil)
+	for _, b := range unmerged {
+		ok, err := gc.SquashMerged(dir, b, root)
+		if err != nil {
+			fmt.Fprintf(out, "[warn] error checking if %s is squash-merged: %s\n", b, err)
+			continue
+		}
+		if ok {
+			squashed = append(squashed, b)
+		}
+	}
+	return merged, squashed, nil
 }
 
 func runMerge(cmd *cobra.Command, args []string) error {
@@ -175,53 +226,284 @@ func runMerge(cmd *cobra.Command, args []string) error {
 
 	gc := git.NewClient(nil)
 
-	returnRoot := viper.GetString("branch-for-return")
-	roots := strings.Split(viper.GetString("branches-for-prune-local"), ",")
-	for _, dir := range cfg.RepoPaths() {
-		wt, err := gc.WorkTree(dir)
-		if err != nil {
+	repos, err := selectRepos(cmd.Context(), cfg)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	var items []interface{}
+	for _, repo := range repos {
+		if seen[repo.Path] {
+			continue
+		}
+		seen[repo.Path] = true
+		items = append(items, repo)
+	}
+
+	// Each repo is independent so a failure in one does not stop the others
+	var lock sync.Mutex
+	var results []MergeResult
+	if err := pdo.DoAll(pdo.DoAllOpt{
+		Func: func(ctx context.Context, item interface{}) error {
+			r := mergeRepo(ctx, gc, item.(*config.Repo))
+			lock.Lock()
+			defer lock.Unlock()
+			results = append(results, r)
+			return nil
+		},
+		Items:           items,
+		Timeout:         viper.GetDuration("timeout"),
+		MaxConcurrent:   viper.GetInt("max-concurrent"),
+		ContinueOnError: true,
+		Context:         cmd.Context(),
+	}); err != nil {
+		// Only repos not started because of cancellation fail here
+		errs, ok := err.(pdo.Errors)
+		if !ok {
 			return err
 		}
+		for _, e := range errs {
+			results = append(results, newMergeResult(e.Item.(*config.Repo).Path, MergeFailed, "", e.Err))
+		}
+	}
 
-		if len(wt.DirtyFiles) != 0 && !viper.GetBool("ignore-dirty") {
-			continue
+	return printMergeResults(results)
+}
+
+// Outcomes of merging a repo
+const (
+	MergeUpdated = "updated" // Brought up to date with upstream
+	MergeSkipped = "skipped" // Nothing done; see Reason
+	MergeManual  = "manual"  // Needs manual attention; see Reason
+	MergeFailed  = "failed"  // Error; see Error
+)
+
+// A MergeResult is the outcome of merge for a repo.
+type MergeResult struct {
+	Result
+	Outcome string `json:"outcome"`          // One of updated, skipped, manual or failed
+	Reason  string `json:"reason,omitempty"` // Why the repo was skipped or needs attention
+}
+
+func newMergeResult(repo, outcome, reason string, err error) MergeResult {
+	r := MergeResult{
+		Result:  newResult(repo, err),
+		Outcome: outcome,
+		Reason:  reason,
+	}
+	if err != nil {
+		r.Outcome = MergeFailed
+	} else if outcome == MergeManual {
+		r.Ok = false
+	}
+	return r
+}
+
+func printMergeResults(results []MergeResult) error {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Repo < results[j].Repo
+	})
+
+	if format := viper.GetString("format"); !isPretty(format) {
+		if err := print(results, format, viper.GetString("filter")); err != nil {
+			return err
 		}
+	} else {
+		prettyMergeResults(results)
+	}
 
-		if viper.GetBool("return") {
-			if err := returnMerged(dir, wt.Commit.Branch, returnRoot); err != nil {
-				return err
+	var failed int
+	for _, r := range results {
+		if !r.Ok {
+			failed += 1
+		}
+	}
+	return failedError(failed, len(results))
+}
+
+func prettyMergeResults(results []MergeResult) {
+	out := colorable.NewColorableStdout()
+	for _, o := range []struct {
+		outcome string
+		heading string
+		color   string
+	}{
+		{MergeUpdated, "updated", "green"},
+		{MergeSkipped, "skipped", "yellow"},
+		{MergeManual, "needs manual attention", "red"},
+		{MergeFailed, "failed", "red"},
+	} {
+		var rs []MergeResult
+		for _, r := range results {
+			if r.Outcome == o.outcome {
+				rs = append(rs, r)
 			}
-			wt, err = gc.WorkTree(dir)
-			if err != nil {
-				return err
+		}
+		if len(rs) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "%s\n", o.heading)
+		for _, r := range rs {
+			reason := r.Reason
+			if len(r.Error) > 0 {
+				reason = r.Error
 			}
+			fmt.Fprintf(out, "    %s %s\n", ansi.Color(r.Repo, o.color), reason)
 		}
+	}
+}
 
-		if viper.GetBool("prune-local") {
-			if err := pruneLocal(dir, roots); err != nil {
-				return err
-			}
+// hasTrackedChanges returns true if any tracked file is modified.
+func hasTrackedChanges(wt *git.WorkTree) bool {
+	for _, f := range wt.Files {
+		if !f.Untracked() {
+			return true
 		}
+	}
+	return false
+}
 
-		mc, err := wt.Commit.UpstreamMerge()
+// mergeRepo merges repo. Cancelling ctx stops the git command that is
+// running; an interrupted rebase is aborted and autostashed changes are
+// restored.
+func mergeRepo(ctx context.Context, gc *git.Client, repo *config.Repo) MergeResult {
+	dir := repo.Path
+	skip := func(reason string) MergeResult {
+		return newMergeResult(dir, MergeSkipped, reason, nil)
+	}
+	manual := func(reason string) MergeResult {
+		return newMergeResult(dir, MergeManual, reason, nil)
+	}
+	fail := func(err error) MergeResult {
+		return newMergeResult(dir, MergeFailed, "", err)
+	}
+
+	wt, err := gc.WorkTree(dir)
+	if err != nil {
+		return fail(err)
+	}
+
+	switch wt.State.Kind {
+	case git.StateRebasing, git.StateMerging:
+		return manual(string(wt.State.Kind))
+	}
+
+	mainBranch := mainline(gc, repo, wt.Repo)
+	returnRoot := viper.GetString("branch-for-return")
+	if len(returnRoot) == 0 {
+		returnRoot = mainBranch
+	}
+	roots := []string{mainBranch}
+	if s := viper.GetString("branches-for-prune-local"); len(s) > 0 {
+		roots = strings.Split(s, ",")
+	}
+
+	dirty := len(wt.DirtyFiles) != 0 && !viper.GetBool("ignore-dirty")
+	if dirty && !viper.GetBool("autostash") {
+		return skip("dirty working tree")
+	}
+
+	if viper.GetBool("return") && !dirty {
+		returned, err := returnMerged(ctx, dir, wt.Commit.Branch, returnRoot)
 		if err != nil {
-			continue
+			return fail(err)
+		}
+		if returned && dryRun() {
+			// Remaining steps depend on the branch after checkout
+			return newMergeResult(dir, MergeUpdated, "would checkout "+returnRoot, nil)
 		}
+		wt, err = gc.WorkTree(dir)
+		if err != nil {
+			return fail(err)
+		}
+	}
 
-		if !mc.CanFFMerge() {
-			continue
+	if viper.GetBool("prune-local") {
+		if err := pruneLocal(ctx, dir, roots); err != nil {
+			return fail(err)
 		}
+	}
 
-		if err := execGitCommand(mc.Current.Repo, "merge", "--ff-only"); err != nil {
-			return err
+	mc, err := wt.Commit.UpstreamMerge()
+	if err != nil {
+		return skip("no upstream branch")
+	}
+
+	if mc.UpToDate() {
+		return skip("up to date")
+	}
+
+	rebase := !mc.CanFFMerge()
+	if rebase && !viper.GetBool("rebase") {
+		return skip(fmt.Sprintf("diverged from %s; use --rebase", mc.Topic.Branch))
+	}
+
+	stashed := false
+	if dirty && hasTrackedChanges(wt) {
+		if err := execGitCommand(ctx, dir, "stash", "push", "-m", "peanut autostash"); err != nil {
+			return fail(err)
+		}
+		stashed = true
+	}
+	// Restoring the work tree must finish even if ctx is done
+	unstash := func() error {
+		if !stashed {
+			return nil
 		}
+		return execGitCommand(context.Background(), dir, "stash", "pop")
+	}
 
-		if err := execGitCommand(mc.Current.Repo, "submodule", "update", "--init", "--recursive"); err != nil {
-			return err
+	if rebase {
+		if err := execGitCommand(ctx, dir, "rebase", mc.Topic.Branch); err != nil {
+			// A rebase stopped by ctx may not have started
+			if wt, err := gc.WorkTree(dir); err != nil || wt.State.Kind == git.StateRebasing {
+				if err := execGitCommand(context.Background(), dir, "rebase", "--abort"); err != nil {
+					return manual(fmt.Sprintf("could not abort rebase onto %s: %s", mc.Topic.Branch, err))
+				}
+			}
+			if err := unstash(); err != nil {
+				return manual("could not restore autostash; changes are in git stash")
+			}
+			if ctx.Err() != nil {
+				return fail(ctx.Err())
+			}
+			return manual(fmt.Sprintf("conflicts rebasing onto %s; rebase aborted", mc.Topic.Branch))
 		}
+	} else if err := execGitCommand(ctx, dir, "merge", "--ff-only"); err != nil {
+		if err := unstash(); err != nil {
+			return manual("could not restore autostash; changes are in git stash")
+		}
+		return fail(err)
 	}
 
-	return nil
+	if err := unstash(); err != nil {
+		return manual("updated but could not restore autostash; changes are in git stash")
+	}
+
+	if err := execGitCommand(ctx, dir, "submodule", "update", "--init", "--recursive"); err != nil {
+		return fail(err)
+	}
+
+	reason := "fast-forwarded to " + mc.Topic.Branch
+	if rebase {
+		reason = "rebased onto " + mc.Topic.Branch
+	}
+	if dryRun() {
+		reason = "would be " + reason
+	}
+	return newMergeResult(dir, MergeUpdated, reason, nil)
+}
+
+// addMergeFlags adds the flags read by mergeRepo to flags.
+func addMergeFlags(flags *pflag.FlagSet, returnDefault, pruneLocalDefault bool) {
+	flags.Bool("ignore-dirty", false, "Ignore dirty working directory when merging")
+	flags.Bool("return", returnDefault, "If current branch has been merged in origin/{branch-for-return}, checkout {branch-for-return}")
+	flags.String("branch-for-return", "", "Branch to treat as root for return (default is the mainline branch)")
+	flags.Bool("rebase", false, "Rebase local commits onto upstream if the branch cannot be fast-forwarded")
+	flags.Bool("autostash", false, "Stash changes in a dirty working directory before updating and restore them after")
+	flags.Bool("prune-local", pruneLocalDefault, "Remove local branches that have been merged in remote {branches-for-prune-local}")
+	flags.String("branches-for-prune-local", "", "Comma-separated list of branches to treat as roots for prune-local (default is the mainline branch)")
 }
 
 func init() {
@@ -229,9 +511,5 @@ func init() {
 	flags := c.Flags()
 
 	RootCmd.AddCommand(c)
-	flags.Bool("ignore-dirty", false, "Ignore dirty working directory when merging")
-	flags.Bool("return", false, "If current branch has been merged in origin/{branch-for-return}, checkout {branch-for-return}")
-	flags.String("branch-for-return", "master", "Branch to treat as root for return")
-	flags.Bool("prune-local", false, "Remove local branches that have been merged in remote {branches-for-prune-local}")
-	flags.String("branches-for-prune-local", "master,stable", "Comma-separated list of branches to treat as roots for prune-local")
+	addMergeFlags(flags, false, false)
 }
@@ -4,35 +4,52 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"reflect"
 
-	"github.com/ddn0/peanut/plog"
 	"github.com/ghodss/yaml"
 )
 
+// isPretty returns true if format is for human consumption rather than
+// machine-readable.
+func isPretty(format string) bool {
+	return format == "pretty"
+}
+
 func print(obj interface{}, format, filter string) error {
 	switch {
-	case format == "text" && len(filter) != 0:
+	case (format == "text" || isPretty(format)) && len(filter) != 0:
 		if t, err := template.New("").Parse(filter); err != nil {
 			return err
-		} else if err := t.Execute(plog.Out, obj); err != nil {
+		} else if err := t.Execute(stdout, obj); err != nil {
 			return err
-		} else if _, err := fmt.Fprintf(plog.Out, "\n"); err != nil {
+		} else if _, err := fmt.Fprintf(stdout, "\n"); err != nil {
 			return err
 		}
-	case format == "text" && len(filter) == 0:
-		if _, err := fmt.Fprintf(plog.Out, "%+v\n", obj); err != nil {
+	case (format == "text" || isPretty(format)) && len(filter) == 0:
+		if _, err := fmt.Fprintf(stdout, "%+v\n", obj); err != nil {
 			return err
 		}
 	case format == "json":
 		if bs, err := json.Marshal(obj); err != nil {
 			return err
-		} else if _, err := fmt.Fprintln(plog.Out, string(bs)); err != nil {
+		} else if _, err := fmt.Fprintln(stdout, string(bs)); err != nil {
 			return err
 		}
+	case format == "ndjson":
+		// One line per element of slices
+		v := reflect.ValueOf(obj)
+		if v.Kind() != reflect.Slice {
+			return print(obj, "json", filter)
+		}
+		for idx := 0; idx < v.Len(); idx += 1 {
+			if err := print(v.Index(idx).Interface(), "json", filter); err != nil {
+				return err
+			}
+		}
 	case format == "yaml":
 		if bs, err := yaml.Marshal(obj); err != nil {
 			return err
-		} else if _, err := fmt.Fprint(plog.Out, string(bs)); err != nil {
+		} else if _, err := fmt.Fprint(stdout, string(bs)); err != nil {
 			return err
 		}
 	default:
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// newProcessGroup does nothing; processes are signalled individually.
+func newProcessGroup(cmd *exec.Cmd) {
+}
+
+// signalGroup sends sig to p only.
+func signalGroup(p *os.Process, sig syscall.Signal) error {
+	if sig == syscall.SIGKILL {
+		return p.Kill()
+	}
+	return p.Signal(sig)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ddn0/peanut/config"
+	"github.com/ddn0/peanut/git"
+	"github.com/spf13/viper"
+)
+
+// taggedRepos returns the repos chosen by the --group and --tag flags.
+func taggedRepos(cfg *config.Config) []*config.Repo {
+	return cfg.Select(viper.GetStringSlice("group"), viper.GetStringSlice("tag"))
+}
+
+// selectRepos returns the repos chosen by the --group and --tag flags that
+// also pass the git state filters (e.g., --dirty).
+func selectRepos(ctx context.Context, cfg *config.Config) ([]*config.Repo, error) {
+	repos := taggedRepos(cfg)
+
+	filter, err := newRepoFilter()
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return repos, nil
+	}
+
+	status, err := readStatus(ctx, repos)
+	if err != nil {
+		return nil, err
+	}
+	// Do not select a subset of the repos if reading was interrupted
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var ret []*config.Repo
+	for idx, s := range status {
+		if len(s.Error) > 0 {
+			continue
+		}
+		if ok, err := filter.Match(&s); err != nil {
+			fmt.Fprintf(os.Stderr, "warn: error filtering %q: %s\n", s.Repo, err)
+		} else if ok {
+			ret = append(ret, repos[idx])
+		}
+	}
+	return ret, nil
+}
+
+// selectRepoPaths returns the paths of the repos returned by selectRepos.
+func selectRepoPaths(ctx context.Context, cfg *config.Config) ([]string, error) {
+	repos, err := selectRepos(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, r := range repos {
+		ret = append(ret, r.Path)
+	}
+	return ret, nil
+}
+
+// mainline returns the mainline branch of repo. In order of precedence, this
+// is the branch recorded in the dir file, the mainline config key, the
+// default branch of origin, and finally master.
+func mainline(gc *git.Client, repo *config.Repo, dir string) string {
+	if len(repo.Branch) > 0 {
+		return repo.Branch
+	}
+	if b := viper.GetString("mainline"); len(b) > 0 {
+		return b
+	}
+	if b, err := gc.DefaultBranch(dir, "origin"); err == nil && len(b) > 0 {
+		return b
+	}
+	return "master"
+}
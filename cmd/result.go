@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/ddn0/peanut/pdo"
+	"github.com/mattn/go-colorable"
+	"github.com/mgutz/ansi"
+	"github.com/spf13/viper"
+)
+
+// A Result is the outcome of a command for a single repo. Commands that
+// operate on repos output a list of Results, sorted by Repo, in
+// machine-readable formats.
+type Result struct {
+	Repo     string `json:"repo"`
+	Ok       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`  // Error message if not Ok
+	ExitCode int    `json:"exit_code"`        // Exit code of the process run in the repo, if any
+	Output   string `json:"output,omitempty"` // File containing the output of the process, if saved
+}
+
+type ResultSlice []Result
+
+func (a ResultSlice) Len() int {
+	return len(a)
+}
+
+func (a ResultSlice) Less(i, j int) bool {
+	return a[i].Repo < a[j].Repo
+}
+
+func (a ResultSlice) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func newResult(repo string, err error) Result {
+	r := Result{
+		Repo:     repo,
+		Ok:       err == nil,
+		ExitCode: exitCode(err),
+		Output:   savedOutput(repo),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// exitCode returns the exit status of the process that returned err, or 1 if
+// err was not caused by a process exiting.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	ee, ok := err.(*exec.ExitError)
+	if !ok {
+		return 1
+	}
+	s, ok := ee.Sys().(syscall.WaitStatus)
+	if !ok || s.ExitStatus() < 0 {
+		return 1
+	}
+	return s.ExitStatus()
+}
+
+// doAllRepos runs fn on each dir in parallel and returns the Result for each
+// dir. A failure in one dir does not stop the others unless failFast is set.
+func doAllRepos(ctx context.Context, dirs []string, failFast bool, fn func(ctx context.Context, dir string) error) ([]Result, error) {
+	var items []interface{}
+	for _, dir := range dirs {
+		items = append(items, dir)
+	}
+
+	var lock sync.Mutex
+	var results []Result
+	err := pdo.DoAll(pdo.DoAllOpt{
+		Func: func(ctx context.Context, item interface{}) error {
+			dir := item.(string)
+			err := fn(ctx, dir)
+			lock.Lock()
+			defer lock.Unlock()
+			results = append(results, newResult(dir, err))
+			return err
+		},
+		Items:           items,
+		Timeout:         viper.GetDuration("timeout"),
+		MaxConcurrent:   viper.GetInt("max-concurrent"),
+		ContinueOnError: true,
+		FailFast:        failFast,
+		Context:         ctx,
+	})
+
+	// Dirs that were not started because of cancellation fail too
+	if errs, ok := err.(pdo.Errors); ok {
+		done := make(map[string]bool)
+		for _, r := range results {
+			done[r.Repo] = true
+		}
+		for _, e := range errs {
+			if dir := e.Item.(string); !done[dir] {
+				results = append(results, newResult(dir, e.Err))
+			}
+		}
+	}
+	return results, err
+}
+
+// printResults outputs results in the selected machine-readable format, if
+// any, and returns an error if any result failed.
+func printResults(results []Result) error {
+	sort.Sort(ResultSlice(results))
+
+	var failed []Result
+	for _, r := range results {
+		if !r.Ok {
+			failed = append(failed, r)
+		}
+	}
+
+	if format := viper.GetString("format"); !isPretty(format) {
+		if err := print(results, format, viper.GetString("filter")); err != nil {
+			return err
+		}
+	} else if len(failed) > 0 {
+		prettyFailures(failed)
+	}
+
+	return failedError(len(failed), len(results))
+}
+
+func prettyFailures(failed []Result) {
+	out := colorable.NewColorableStdout()
+	var width int
+	for _, r := range failed {
+		if n := len(r.Repo); n > width {
+			width = n
+		}
+	}
+
+	fmt.Fprintf(out, "failed\n")
+	for _, r := range failed {
+		fmt.Fprintf(out, "    %s %s %s\n",
+			ansi.Color(fmt.Sprintf("%-*s", width, r.Repo), "cyan"),
+			ansi.Color(fmt.Sprintf("exit %3d", r.ExitCode), "red"),
+			r.Error)
+		if len(r.Output) > 0 {
+			fmt.Fprintf(out, "    %-*s see %s\n", width+len("exit 123"), "", r.Output)
+		}
+	}
+}
+
+func failedError(failed, total int) error {
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d repos failed", failed, total)
+}
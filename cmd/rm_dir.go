@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ddn0/peanut/config"
+	"github.com/ddn0/peanut/logwriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var rmDirCmd = &cobra.Command{
+	Use:   "rm-dir [dirs]",
+	Short: "remove directory from config",
+	RunE:  runRmDir,
+}
+
+// findRepo returns the repo registered for dir, which need not exist, or nil.
+func findRepo(cfg *config.Config, dir string) *config.Repo {
+	if repo := cfg.Find(dir); repo != nil {
+		return repo
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	if repo := cfg.Find(abs); repo != nil {
+		return repo
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return cfg.Find(resolved)
+	}
+	return nil
+}
+
+func runRmDir(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		args = append(args, wd)
+	}
+
+	lw := logwriter.NewColorWriter("")
+	defer lw.Flush()
+	var results []Result
+	if err := updateConf(func(cfg *config.Config) error {
+		for _, arg := range args {
+			repo := findRepo(cfg, arg)
+			if repo == nil {
+				err := fmt.Errorf("not in %s", dirFile())
+				fmt.Fprintf(lw, "[warn] error removing %s: %s\n", arg, err)
+				results = append(results, newResult(arg, err))
+				continue
+			}
+			cfg.Remove(repo.Path)
+			results = append(results, newResult(repo.Path, nil))
+			if dryRun() {
+				fmt.Fprintf(lw, "would remove %s\n", repo.Path)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return printResults(results)
+}
+
+func init() {
+	c := rmDirCmd
+
+	RootCmd.AddCommand(c)
+}
@@ -1,34 +1,66 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
+	"github.com/ddn0/peanut/plog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
 	cfgFile string
-	stderr  = os.Stderr
-	stdout  = os.Stdout
+	stdin             = os.Stdin
+	stderr  io.Writer = os.Stderr // Messages go through the dashboard, if any
+	stdout            = os.Stdout
 )
 
 var RootCmd = &cobra.Command{
-	Use:           "peanut",
-	SilenceUsage:  true,
-	SilenceErrors: true,
+	Use:               "peanut",
+	SilenceUsage:      true,
+	SilenceErrors:     true,
+	PersistentPreRunE: preRun,
 }
 
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
+	// Cancel outstanding operations on the first interrupt and exit
+	// immediately on the second
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := RootCmd.ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(stderr, err)
 		os.Exit(-1)
 	}
 }
 
+// dryRun returns true if commands should not change any state.
+func dryRun() bool {
+	return viper.GetBool("dry-run")
+}
+
+func preRun(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	// Keep stdout for machine-readable output
+	if !isPretty(viper.GetString("format")) {
+		plog.Out = stderr
+	}
+	return nil
+}
+
 func configDir() string {
 	if d, p := os.Getenv("HOMEDRIVE"), os.Getenv("HOMEPATH"); len(d) > 0 && len(p) > 0 {
 		return filepath.Join(d, p, ".peanut")
@@ -43,9 +75,22 @@ func init() {
 
 	flags.StringVar(&cfgFile, "config", "", "Config file (default is $HOME/.peanut/config.yaml)")
 	flags.Bool("verbose", false, "Print more output")
-	flags.String("dir", filepath.Join(configDir(), "dir"), "Path to package directory file")
+	flags.String("dir", filepath.Join(configDir(), "dir"), "Path to package directory file; if not set, the nearest .peanut.yaml in the current directory or its parents takes precedence")
 	flags.Int("max-concurrent", 8, "Maximum number of concurrent operations to attempt")
 	flags.Duration("timeout", 5*time.Minute, "Timeout")
+	flags.String("mainline", "", "Mainline branch of repos (default is the default branch of origin)")
+	flags.Bool("dry-run", false, "Print what mutating commands would do without doing it")
+	flags.Duration("grace-period", 5*time.Second, "Time to wait after interrupting a process before killing it")
+	flags.StringSlice("group", nil, "Only operate on repos in these groups")
+	flags.StringSlice("tag", nil, "Only operate on repos with any of these tags")
+	flags.Bool("dirty", false, "Only operate on repos with uncommitted changes")
+	flags.Bool("behind", false, "Only operate on repos behind their upstream branch")
+	flags.String("branch", "", "Only operate on repos whose current branch matches this glob")
+	flags.String("changed-since", "", "Only operate on repos whose HEAD differs from this commit")
+	flags.StringSlice("changed-paths", nil, "Limit changed-since to these paths")
+	flags.String("where", "", "Only operate on repos for which this go template evaluated against Status is true")
+	flags.String("format", "pretty", "Output format {pretty,json,yaml,text,ndjson}")
+	flags.String("filter", "", "Filter text format using go package template")
 }
 
 func initConfig() {
@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ddn0/peanut/dashboard"
+	"github.com/ddn0/peanut/events"
+	"github.com/ddn0/peanut/logwriter"
+	"github.com/ddn0/peanut/plog"
+	"github.com/mattn/go-colorable"
+	"github.com/spf13/viper"
+)
+
+// If not nil, emit events for processes run by runInRepo instead of logging
+// their output
+var emitter *events.Emitter
+
+// enableEvents writes events for processes to stdout and moves other logging
+// to stderr. Events cannot be combined with machine-readable output formats,
+// which also write to stdout.
+func enableEvents() error {
+	if format := viper.GetString("format"); !isPretty(format) {
+		return fmt.Errorf("--events cannot be used with --format %s", format)
+	}
+	emitter = events.New(stdout)
+	plog.Out = stderr
+	return nil
+}
+
+// If not nil, show progress of processes run by runInRepo on a dashboard
+// instead of logging their output
+var dash *dashboard.Dashboard
+
+// Output of processes held back while the dashboard is shown
+var (
+	heldLock   sync.Mutex
+	heldOutput = make(map[string]*groupWriter)
+)
+
+// holdOutput returns a writer whose output is printed when the dashboard
+// stops in the order of its rows.
+func holdOutput(dir string) flushWriter {
+	gw := &groupWriter{prefix: filepath.Base(dir)}
+	heldLock.Lock()
+	defer heldLock.Unlock()
+	heldOutput[dir] = gw
+	return nopFlusher{gw}
+}
+
+// startDashboard shows a dashboard for dirs if requested, the output format
+// is pretty and stdout is a terminal. While the dashboard is shown, messages
+// are printed above it and the stdout of processes is held back until it
+// stops. It returns a function to stop the dashboard.
+func startDashboard(dirs []string) func() {
+	if !viper.GetBool("dashboard") || emitter != nil || !isPretty(viper.GetString("format")) || !dashboard.IsTerminal(stdout) {
+		return func() {}
+	}
+	var names []string
+	for _, dir := range dirs {
+		names = append(names, filepath.Base(dir))
+	}
+	dash = dashboard.New(colorable.NewColorableStdout(), dirs, names)
+	oldStderr, oldOut := stderr, plog.Out
+	stderr, plog.Out = dash, dash
+	dash.Start(200 * time.Millisecond)
+	return func() {
+		dash.Stop()
+		dash = nil
+		stderr, plog.Out = oldStderr, oldOut
+
+		heldLock.Lock()
+		defer heldLock.Unlock()
+		for _, dir := range dirs {
+			if gw, ok := heldOutput[dir]; ok {
+				gw.Flush()
+				delete(heldOutput, dir)
+			}
+		}
+	}
+}
+
+type nopFlusher struct {
+	io.Writer
+}
+
+func (a nopFlusher) Flush() error {
+	return nil
+}
+
+type flushWriter interface {
+	io.Writer
+	Flush() error
+}
+
+// Serializes output of groupWriters
+var groupLock sync.Mutex
+
+// A groupWriter buffers all output of a process and logs it at once on Flush.
+type groupWriter struct {
+	prefix string
+	buf    bytes.Buffer
+	lock   sync.Mutex
+}
+
+func (a *groupWriter) Write(p []byte) (int, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.buf.Write(p)
+}
+
+func (a *groupWriter) Flush() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.buf.Len() == 0 {
+		return nil
+	}
+
+	groupLock.Lock()
+	defer groupLock.Unlock()
+	lw := logwriter.NewColorWriter(a.prefix)
+	if _, err := lw.Write(a.buf.Bytes()); err != nil {
+		return err
+	}
+	a.buf.Reset()
+	return lw.Flush()
+}
+
+// A teeWriter writes to a flushWriter and a file.
+type teeWriter struct {
+	flushWriter
+	file io.Writer
+}
+
+func (a *teeWriter) Write(p []byte) (int, error) {
+	if _, err := a.file.Write(p); err != nil {
+		return 0, err
+	}
+	return a.flushWriter.Write(p)
+}
+
+// outputFile returns the file that the output of a process run in dir is
+// saved to or "" if output is not saved.
+func outputFile(dir string) string {
+	outDir := viper.GetString("output-dir")
+	if len(outDir) == 0 {
+		return ""
+	}
+	name := strings.Trim(filepath.ToSlash(filepath.Clean(dir)), "/")
+	name = strings.NewReplacer("/", "_", ":", "_").Replace(name)
+	return filepath.Join(outDir, name+".log")
+}
+
+// Files that the output of processes was saved to by dir
+var (
+	outputLock  sync.Mutex
+	outputFiles = make(map[string]string)
+)
+
+// savedOutput returns the file that the output of the process run in dir was
+// saved to or "" if no output was saved.
+func savedOutput(dir string) string {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	return outputFiles[dir]
+}
+
+// repoWriters returns the writers for the stdout and stderr of a process run
+// in dir, the file that output is saved to, if any, and a function to close
+// them.
+func repoWriters(dir string) (stdout, stderr flushWriter, file string, closer func() error, err error) {
+	closer = func() error { return nil }
+	if emitter != nil {
+		stdout, stderr = emitter.Writer(dir, "stdout"), emitter.Writer(dir, "stderr")
+	} else if dash != nil {
+		stdout, stderr = holdOutput(dir), dash.Writer(dir)
+	} else if viper.GetBool("group-output") {
+		gw := &groupWriter{prefix: filepath.Base(dir)}
+		stdout, stderr = gw, gw
+	} else {
+		lw := logwriter.NewColorWriter(filepath.Base(dir))
+		stdout, stderr = lw, lw
+	}
+
+	if fn := outputFile(dir); len(fn) > 0 {
+		if err := os.MkdirAll(filepath.Dir(fn), 0777); err != nil {
+			return nil, nil, "", nil, err
+		}
+		f, err := os.Create(fn)
+		if err != nil {
+			return nil, nil, "", nil, err
+		}
+		var lock sync.Mutex
+		lf := lockedWriter{w: f, lock: &lock}
+		stdout = &teeWriter{flushWriter: stdout, file: lf}
+		stderr = &teeWriter{flushWriter: stderr, file: lf}
+		file = fn
+		closer = f.Close
+	}
+	return stdout, stderr, file, closer, nil
+}
+
+// A lockedWriter serializes writes to w.
+type lockedWriter struct {
+	w    io.Writer
+	lock *sync.Mutex
+}
+
+func (a lockedWriter) Write(p []byte) (int, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.w.Write(p)
+}
+
+// notStarted marks dir as failed on the dashboard, if any, when the process
+// for dir could not be started because of err. It returns err.
+func notStarted(dir string, err error) error {
+	if dash != nil {
+		dash.Finished(dir, err)
+	}
+	return err
+}
+
+// runInRepo runs cmd on behalf of the repo in dir. If ctx is done before the
+// process exits, the process is sent SIGTERM and then killed after the grace
+// period.
+func runInRepo(ctx context.Context, dir string, cmd *exec.Cmd) error {
+	stdout, stderr, file, closer, err := repoWriters(dir)
+	if err != nil {
+		return notStarted(dir, err)
+	}
+	defer closer()
+	if len(file) > 0 {
+		outputLock.Lock()
+		outputFiles[dir] = file
+		outputLock.Unlock()
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	if emitter != nil {
+		emitter.Started(dir)
+	}
+	if dash != nil {
+		dash.Started(dir)
+	}
+
+	err = runCmd(ctx, cmd)
+
+	// Flush before reporting that the process exited so that its last line
+	// of output comes first
+	stdout.Flush()
+	if stderr != stdout {
+		stderr.Flush()
+	}
+	if emitter != nil {
+		emitter.Exited(dir, exitCode(err), time.Since(start), err)
+	}
+	if dash != nil {
+		dash.Finished(dir, err)
+	}
+	return err
+}
+
+// runCmd runs cmd and waits for it to exit. If ctx is done before the process
+// exits, the process and its children are stopped (see stop) and ctx.Err() is
+// returned. Children left holding its output open are waited for at most the
+// grace period after it exits.
+func runCmd(ctx context.Context, cmd *exec.Cmd) error {
+	newProcessGroup(cmd)
+	cmd.WaitDelay = viper.GetDuration("grace-period")
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- cmd.Wait()
+	}()
+
+	select {
+	case <-ctx.Done():
+		stop(cmd.Process, errs)
+		return ctx.Err()
+	case err := <-errs:
+		if err == exec.ErrWaitDelay {
+			// Exited successfully but left children running
+			return nil
+		}
+		return err
+	}
+}
+
+// stop sends SIGTERM to the process group of p and waits for p to exit,
+// killing the group after the grace period.
+func stop(p *os.Process, exited <-chan error) {
+	if err := signalGroup(p, syscall.SIGTERM); err != nil {
+		signalGroup(p, syscall.SIGKILL)
+	}
+	select {
+	case <-exited:
+	case <-time.After(viper.GetDuration("grace-period")):
+		signalGroup(p, syscall.SIGKILL)
+		<-exited
+	}
+}
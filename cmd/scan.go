@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ddn0/peanut/config"
+	"github.com/ddn0/peanut/git"
+	"github.com/ddn0/peanut/logwriter"
+	"github.com/ddn0/peanut/pdo"
+	"github.com/mattn/go-colorable"
+	"github.com/mgutz/ansi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan [roots]",
+	Short: "add git repos found under directories to config",
+	Long: `Walk each root directory to find git repos and worktrees, add new ones
+to the dir file and report registered repos under the roots that no longer
+exist.
+
+If no roots are given, scan the roots listed under "roots" in the dir file.`,
+	RunE: runScan,
+}
+
+// Changes found by scan
+const (
+	ScanAdded   = "added"   // Found on disk but not in the dir file
+	ScanMissing = "missing" // In the dir file but not found on disk
+)
+
+// A ScanResult is a difference between the repos under the scanned roots and
+// the dir file.
+type ScanResult struct {
+	Repo   string `json:"repo"`
+	Change string `json:"change"` // One of added or missing
+}
+
+// isRepo returns true if dir is the top level of a git repo or worktree.
+// Submodules are not considered repos.
+func isRepo(dir string) bool {
+	fi, err := os.Lstat(filepath.Join(dir, ".git"))
+	if err != nil {
+		return false
+	}
+	if fi.IsDir() {
+		return true
+	}
+	bs, err := ioutil.ReadFile(filepath.Join(dir, ".git"))
+	if err != nil {
+		return false
+	}
+	gitDir := filepath.ToSlash(strings.TrimSpace(strings.TrimPrefix(string(bs), "gitdir:")))
+	return !strings.Contains(gitDir, "/modules/")
+}
+
+// ignored returns true if the base name of dir matches any of globs.
+func ignored(dir string, globs []string) bool {
+	name := filepath.Base(dir)
+	if name == ".git" {
+		return true
+	}
+	for _, g := range globs {
+		if ok, _ := path.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// subdirs returns the directories in dir that are not ignored. Symlinks are
+// not followed.
+func subdirs(dir string, globs []string) ([]string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		sub := filepath.Join(dir, fi.Name())
+		if ignored(sub, globs) {
+			continue
+		}
+		ret = append(ret, sub)
+	}
+	return ret, nil
+}
+
+// walkRepos calls fn for each repo in dir and its subdirectories up to
+// maxDepth levels below a root, where dir is depth levels below the root.
+func walkRepos(ctx context.Context, dir string, depth, maxDepth int, globs []string, fn func(string)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if isRepo(dir) {
+		fn(dir)
+	}
+	if depth >= maxDepth {
+		return nil
+	}
+	dirs, err := subdirs(dir, globs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warn: error reading %q: %s\n", dir, err)
+		return nil
+	}
+	for _, d := range dirs {
+		if err := walkRepos(ctx, d, depth+1, maxDepth, globs, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanRoots returns the repos under roots. Each subdirectory of a root is
+// walked in parallel.
+func scanRoots(ctx context.Context, roots []string) ([]string, error) {
+	maxDepth := viper.GetInt("max-depth")
+	globs := viper.GetStringSlice("ignore")
+
+	var lock sync.Mutex
+	var repos []string
+	add := func(dir string) {
+		lock.Lock()
+		defer lock.Unlock()
+		repos = append(repos, dir)
+	}
+
+	type walk struct {
+		dir   string
+		depth int
+	}
+	var items []interface{}
+	for _, root := range roots {
+		if isRepo(root) {
+			add(root)
+		}
+		if maxDepth < 1 {
+			continue
+		}
+		dirs, err := subdirs(root, globs)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range dirs {
+			items = append(items, walk{dir: d, depth: 1})
+		}
+	}
+
+	if err := pdo.DoAll(pdo.DoAllOpt{
+		Func: func(ctx context.Context, item interface{}) error {
+			w := item.(walk)
+			return walkRepos(ctx, w.dir, w.depth, maxDepth, globs, add)
+		},
+		Items:         items,
+		Timeout:       viper.GetDuration("timeout"),
+		MaxConcurrent: viper.GetInt("max-concurrent"),
+		Context:       ctx,
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(repos)
+	return repos, nil
+}
+
+// underRoot returns true if dir is root or a subdirectory of root.
+func underRoot(dir, root string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func prettyScanResults(results []ScanResult) {
+	out := colorable.NewColorableStdout()
+	for _, c := range []struct {
+		change  string
+		heading string
+		color   string
+	}{
+		{ScanAdded, "added", "green"},
+		{ScanMissing, "no longer found on disk", "red"},
+	} {
+		var rs []ScanResult
+		for _, r := range results {
+			if r.Change == c.change {
+				rs = append(rs, r)
+			}
+		}
+		if len(rs) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "%s\n", c.heading)
+		for _, r := range rs {
+			fmt.Fprintf(out, "    %s\n", ansi.Color(r.Repo, c.color))
+		}
+	}
+}
+
+func runScan(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	cfg, err := readConf()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		args = cfg.Roots
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("no roots given and no roots in %s", dirFile())
+	}
+
+	// Resolve roots like git resolves the top level of a repo so that found
+	// paths match the paths recorded by add-dir
+	var roots []string
+	for _, arg := range args {
+		root, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		if root, err = filepath.EvalSymlinks(root); err != nil {
+			return err
+		}
+		roots = append(roots, root)
+	}
+
+	found, err := scanRoots(cmd.Context(), roots)
+	if err != nil {
+		return err
+	}
+
+	group := viper.GetString("set-group")
+	tags := viper.GetStringSlice("add-tag")
+
+	lw := logwriter.NewColorWriter("")
+	defer lw.Flush()
+	client := git.NewClient(nil)
+	var results []ScanResult
+	if err := updateConf(func(cfg *config.Config) error {
+		onDisk := make(map[string]bool)
+		for _, dir := range found {
+			onDisk[dir] = true
+			if cfg.Find(dir) != nil {
+				continue
+			}
+			repo := &config.Repo{
+				Path:  dir,
+				Group: group,
+			}
+			repo.URL, _ = client.RemoteURL(dir, "origin")
+			repo.AddTags(tags...)
+			cfg.Repos = append(cfg.Repos, repo)
+			results = append(results, ScanResult{Repo: dir, Change: ScanAdded})
+			if dryRun() {
+				fmt.Fprintf(lw, "would add %s\n", dir)
+			}
+		}
+
+		for _, repo := range cfg.Repos {
+			if onDisk[repo.Path] || isRepo(repo.Path) {
+				continue
+			}
+			for _, root := range roots {
+				if underRoot(repo.Path, root) {
+					results = append(results, ScanResult{Repo: repo.Path, Change: ScanMissing})
+					break
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	format := viper.GetString("format")
+	if isPretty(format) {
+		prettyScanResults(results)
+		return nil
+	}
+	return print(results, format, viper.GetString("filter"))
+}
+
+func init() {
+	c := scanCmd
+	flags := c.Flags()
+
+	RootCmd.AddCommand(c)
+	flags.Int("max-depth", 4, "Maximum depth of directories below a root to search")
+	flags.StringSlice("ignore", []string{"node_modules", "vendor"}, "Do not search directories whose name matches any of these globs")
+	flags.String("set-group", "", "Group to place new repos in")
+	flags.StringSlice("add-tag", nil, "Tags to add to new repos")
+}
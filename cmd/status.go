@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sort"
 	"strings"
 
+	"github.com/ddn0/peanut/config"
 	"github.com/ddn0/peanut/git"
+	"github.com/ddn0/peanut/pdo"
 	"github.com/dustin/go-humanize"
 	"github.com/mattn/go-colorable"
 	"github.com/mgutz/ansi"
@@ -21,15 +24,21 @@ var statusCmd = &cobra.Command{
 }
 
 type Status struct {
-	Repo             string
-	Commit           *git.Commit
-	Dirty            bool
-	DirtyFiles       []string
-	LastN            []git.Log
-	Unpushed         []git.Log
-	Unmerged         []git.Log
-	Missing          []git.Log
-	UnmergedBranches []string
+	Repo             string      `json:"repo"`
+	Error            string      `json:"error,omitempty"` // Error reading status; other fields are empty
+	Mainline         string      `json:"mainline"`        // Mainline branch name
+	Commit           *git.Commit `json:"commit"`
+	Dirty            bool        `json:"dirty"`
+	DirtyFiles       []string    `json:"dirty_files"`
+	Files            []git.File  `json:"files"`
+	Ahead            int         `json:"ahead"`  // Commits ahead of upstream
+	Behind           int         `json:"behind"` // Commits behind upstream
+	State            git.State   `json:"state"`
+	LastN            []git.Log   `json:"last_n"`
+	Unpushed         []git.Log   `json:"unpushed"`
+	Unmerged         []git.Log   `json:"unmerged"`
+	Missing          []git.Log   `json:"missing"`
+	UnmergedBranches []string    `json:"unmerged_branches"`
 }
 
 type StatusSlice []Status
@@ -46,30 +55,39 @@ func (a StatusSlice) Swap(i, j int) {
 	a[i], a[j] = a[j], a[i]
 }
 
-func newStatus(dir string) (*Status, error) {
-	gc := git.NewClient(nil)
-	wt, err := gc.WorkTree(dir)
+// newStatus reads the status of repo. Git processes are killed when ctx is
+// done.
+func newStatus(ctx context.Context, repo *config.Repo) (*Status, error) {
+	gc := git.NewClient(&git.ClientOpt{Context: ctx})
+	wt, err := gc.WorkTree(repo.Path)
 	if err != nil {
 		return nil, err
 	}
+	mainBranch := mainline(gc, repo, wt.Repo)
+	remoteMain := "origin/" + mainBranch
 	upstream := wt.Commit.Upstream
 	if len(upstream) == 0 {
-		upstream = "origin/master"
+		upstream = remoteMain
 	}
 	unpushed, _ := gc.Logs(wt.Repo, wt.Commit.Sha, git.RevListNot(upstream))
 	unmerged, _ := gc.Logs(wt.Repo, git.RevListNot(wt.Commit.Sha), upstream)
 	var missing []git.Log
-	if upstream != "origin/master" {
-		missing, _ = gc.Logs(wt.Repo, git.RevListNot(wt.Commit.Sha), "origin/master")
+	if upstream != remoteMain {
+		missing, _ = gc.Logs(wt.Repo, git.RevListNot(wt.Commit.Sha), remoteMain)
 	}
-	unmergedB, _ := wt.UnmergedBranches("origin/master")
+	unmergedB, _ := wt.UnmergedBranches(remoteMain)
 	last, _ := gc.Logs(wt.Repo, wt.Commit.Sha, git.RevListNot(git.FirstParent(wt.Commit.Sha)))
 
 	return &Status{
 		Repo:             wt.Repo,
+		Mainline:         mainBranch,
 		Commit:           wt.Commit,
 		Dirty:            len(wt.DirtyFiles) > 0,
 		DirtyFiles:       wt.DirtyFiles,
+		Files:            wt.Files,
+		Ahead:            wt.Ahead,
+		Behind:           wt.Behind,
+		State:            wt.State,
 		LastN:            last,
 		Unpushed:         unpushed,
 		Unmerged:         unmerged,
@@ -96,6 +114,22 @@ func prettyStatus(status []Status) error {
 			fmt.Fprintf(out, "    %s %s (%s)\n", ansi.Color(sha, color), subject, htime)
 		}
 	}
+	printFiles := func(fs []git.File, pred func(git.File) bool, heading, color string) {
+		var matched []git.File
+		for _, f := range fs {
+			if pred(f) {
+				matched = append(matched, f)
+			}
+		}
+		if len(matched) == 0 {
+			return
+		}
+
+		fmt.Fprintf(out, "  %s\n", heading)
+		for _, f := range matched {
+			fmt.Fprintln(out, "    ", ansi.Color(f.String(), color))
+		}
+	}
 	printBranches := func(bs []string, heading, color string) {
 		if len(bs) == 0 {
 			return
@@ -111,17 +145,22 @@ func prettyStatus(status []Status) error {
 	}
 
 	for _, s := range status {
+		if len(s.Error) > 0 {
+			continue
+		}
 		var branch string
-		if s.Commit.Branch != "master" {
+		if s.Commit.Branch != s.Mainline {
 			branch = ansi.Color(fmt.Sprintf("(%s)", s.Commit.Branch), "170")
 		}
-		fmt.Fprintln(out, ansi.Color(s.Repo, "cyan"), branch)
-		if s.Dirty {
-			fmt.Fprintf(out, "  Dirty:\n")
-			for _, f := range s.DirtyFiles {
-				fmt.Fprintln(out, "    ", ansi.Color(f, "red"))
-			}
+		var ab string
+		if s.Ahead > 0 || s.Behind > 0 {
+			ab = ansi.Color(fmt.Sprintf("[ahead %d, behind %d]", s.Ahead, s.Behind), "yellow")
 		}
+		fmt.Fprintln(out, ansi.Color(s.Repo, "cyan"), branch, ab)
+		printFiles(s.Files, git.File.Conflicted, "Conflicted:", "red+b")
+		printFiles(s.Files, git.File.Staged, "Staged:", "green")
+		printFiles(s.Files, git.File.Unstaged, "Unstaged:", "red")
+		printFiles(s.Files, git.File.Untracked, "Untracked:", "red")
 		printLog(s.Unmerged, "Unmerged:", "blue")
 		printLog(s.Missing, "Missing:", "blue")
 		printLog(s.Unpushed, "Unpushed:", "yellow")
@@ -131,46 +170,147 @@ func prettyStatus(status []Status) error {
 	return nil
 }
 
-func runStatus(cmd *cobra.Command, args []string) error {
-	if err := viper.BindPFlags(cmd.Flags()); err != nil {
-		return err
+// readStatus reads the status of repos in parallel. The ith status is the
+// status of the ith repo. Repos whose status cannot be read are reported and
+// have Status.Error set.
+func readStatus(ctx context.Context, repos []*config.Repo) ([]Status, error) {
+	var items []interface{}
+	for idx := range repos {
+		items = append(items, idx)
 	}
 
-	cfg, err := readConf()
+	status := make([]Status, len(repos))
+	if err := pdo.DoAll(pdo.DoAllOpt{
+		Func: func(ctx context.Context, item interface{}) error {
+			idx := item.(int)
+			repo := repos[idx]
+			type result struct {
+				s   *Status
+				err error
+			}
+			results := make(chan result, 1)
+			go func() {
+				s, err := newStatus(ctx, repo)
+				results <- result{s: s, err: err}
+			}()
+
+			var r result
+			select {
+			case <-ctx.Done():
+				r.err = ctx.Err()
+			case r = <-results:
+			}
+
+			if r.err != nil {
+				fmt.Fprintf(os.Stderr, "warn: error reading git status of %q: %s\n", repo.Path, r.err)
+				status[idx] = Status{
+					Repo:  repo.Path,
+					Error: r.err.Error(),
+				}
+				return nil
+			}
+			status[idx] = *r.s
+			return nil
+		},
+		Items:           items,
+		Timeout:         viper.GetDuration("timeout"),
+		MaxConcurrent:   viper.GetInt("max-concurrent"),
+		ContinueOnError: true,
+		Context:         ctx,
+	}); err != nil {
+		// Only repos not read because of cancellation fail here
+		errs, ok := err.(pdo.Errors)
+		if !ok {
+			return nil, err
+		}
+		for _, e := range errs {
+			idx := e.Item.(int)
+			status[idx] = Status{
+				Repo:  repos[idx].Path,
+				Error: e.Err.Error(),
+			}
+		}
+	}
+	return status, nil
+}
+
+// collectStatus reads the status of each selected repo in parallel. Repos
+// whose status cannot be read are reported and have Status.Error set.
+func collectStatus(ctx context.Context, cfg *config.Config) ([]Status, error) {
+	filter, err := newRepoFilter()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	all, err := readStatus(ctx, taggedRepos(cfg))
+	if err != nil {
+		return nil, err
 	}
 
 	seen := make(map[string]bool)
 	var status []Status
-	for _, dir := range cfg.RepoPaths() {
-		s, err := newStatus(dir)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "warn: error reading git status of %q: %s\n", dir, err)
-			continue
-		}
+	for _, s := range all {
 		if seen[s.Repo] {
 			continue
 		}
 		seen[s.Repo] = true
-
-		status = append(status, *s)
+		if len(s.Error) == 0 && filter != nil {
+			if ok, err := filter.Match(&s); err != nil {
+				fmt.Fprintf(os.Stderr, "warn: error filtering %q: %s\n", s.Repo, err)
+				s = Status{
+					Repo:  s.Repo,
+					Error: err.Error(),
+				}
+			} else if !ok {
+				continue
+			}
+		}
+		status = append(status, s)
 	}
 
 	sort.Sort(StatusSlice(status))
+	return status, nil
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	cfg, err := readConf()
+	if err != nil {
+		return err
+	}
+
+	status, err := collectStatus(cmd.Context(), cfg)
+	if err != nil {
+		return err
+	}
 
-	if format := viper.GetString("format"); format == "pretty" {
-		return prettyStatus(status)
+	if format := viper.GetString("format"); isPretty(format) {
+		err = prettyStatus(status)
 	} else {
-		return print(status, format, viper.GetString("filter"))
+		err = print(status, format, viper.GetString("filter"))
+	}
+	if err != nil {
+		return err
+	}
+	return statusError(status)
+}
+
+// statusError returns an error if the status of any repo could not be read.
+func statusError(status []Status) error {
+	var failed int
+	for _, s := range status {
+		if len(s.Error) > 0 {
+			failed += 1
+		}
 	}
+	return failedError(failed, len(status))
 }
 
 func init() {
 	c := statusCmd
-	flags := c.Flags()
 
 	RootCmd.AddCommand(c)
-	flags.String("format", "pretty", "Output format {pretty,json,text,yaml}")
-	flags.String("filter", "", "Filter text format using go package template")
 }
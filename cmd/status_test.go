@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ddn0/peanut/config"
+)
+
+func TestReadStatusCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	repos := []*config.Repo{{Path: "/a"}, {Path: "/b"}}
+	status, err := readStatus(ctx, repos)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for idx, s := range status {
+		if s.Repo != repos[idx].Path || len(s.Error) == 0 {
+			t.Errorf("expected %s to fail but found %+v", repos[idx].Path, s)
+		}
+	}
+	if err := statusError(status); err == nil {
+		t.Errorf("expected error for cancelled status")
+	}
+}
@@ -2,9 +2,7 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"path"
-	"sort"
 	"strings"
 
 	"github.com/ddn0/peanut/git"
@@ -21,8 +19,63 @@ var summaryCmd = &cobra.Command{
 	RunE:  runSummary,
 }
 
+// fileCounts returns a brief description of the number of conflicted (!),
+// staged (+), unstaged (~) and untracked (?) files.
+func fileCounts(fs []git.File) string {
+	var conflicted, staged, unstaged, untracked int
+	for _, f := range fs {
+		switch {
+		case f.Conflicted():
+			conflicted += 1
+		case f.Untracked():
+			untracked += 1
+		default:
+			if f.Staged() {
+				staged += 1
+			}
+			if f.Unstaged() {
+				unstaged += 1
+			}
+		}
+	}
+
+	var parts []string
+	for _, c := range []struct {
+		n      int
+		symbol string
+	}{
+		{conflicted, "!"},
+		{staged, "+"},
+		{unstaged, "~"},
+		{untracked, "?"},
+	} {
+		if c.n > 0 {
+			parts = append(parts, fmt.Sprintf("%s%d", c.symbol, c.n))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func stateColor(s git.State) string {
+	switch s.Kind {
+	case git.StateClean:
+		return "green"
+	case git.StateDirty, git.StateAhead, git.StateBehind:
+		return "yellow"
+	default:
+		return "red"
+	}
+}
+
 func prettySummary(status []Status) error {
 	out := colorable.NewColorableStdout()
+	var width int
+	for _, s := range status {
+		if n := len(s.State.String()); n > width {
+			width = n
+		}
+	}
+
 	printStatus := func(status []Status, color string) {
 		for _, s := range status {
 			last := git.Log{
@@ -41,29 +94,35 @@ func prettySummary(status []Status) error {
 			htime := humanize.Time(last.AuthorDate)
 			dir, fn := path.Split(s.Repo)
 			srepo := path.Join(path.Base(dir), fn)
-			fmt.Fprintf(out, "    %s [%s] %s (%s)\n",
+			if counts := fileCounts(s.Files); len(counts) > 0 {
+				srepo += " " + counts
+			}
+			fmt.Fprintf(out, "    %s %s [%s] %s (%s)\n",
 				ansi.Color(sha, color),
+				ansi.Color(fmt.Sprintf("%-*s", width, s.State), stateColor(s.State)),
 				ansi.Color(srepo, "cyan"),
 				subject,
 				htime)
 		}
 	}
 
-	var master []Status
+	var onMainline []Status
 	var dirty []Status
 	var other []Status
 
 	for _, s := range status {
 		switch {
+		case len(s.Error) > 0:
+			continue
 		case s.Dirty:
 			dirty = append(dirty, s)
 		case len(s.Unmerged) > 0:
 			dirty = append(dirty, s)
 		case len(s.Unpushed) > 0:
 			other = append(other, s)
-		case s.Commit.Branch == "master":
-			master = append(master, s)
-		case s.Commit.Branch != "master":
+		case s.Commit.Branch == s.Mainline:
+			onMainline = append(onMainline, s)
+		case s.Commit.Branch != s.Mainline:
 			other = append(other, s)
 		default:
 			// Shouldn't happen...
@@ -71,9 +130,9 @@ func prettySummary(status []Status) error {
 		}
 	}
 
-	if len(master) > 0 {
-		fmt.Fprintf(out, "on master branch and up-to-date\n")
-		printStatus(master, "green")
+	if len(onMainline) > 0 {
+		fmt.Fprintf(out, "on mainline branch and up-to-date\n")
+		printStatus(onMainline, "green")
 	}
 	if len(other) > 0 {
 		fmt.Fprintf(out, "on another branch or unpushed\n")
@@ -97,25 +156,20 @@ func runSummary(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	seen := make(map[string]bool)
-	var status []Status
-	for _, dir := range cfg.RepoPaths() {
-		s, err := newStatus(dir)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "warn: error reading git status of %q: %s\n", dir, err)
-			continue
-		}
-		if seen[s.Repo] {
-			continue
-		}
-		seen[s.Repo] = true
-
-		status = append(status, *s)
+	status, err := collectStatus(cmd.Context(), cfg)
+	if err != nil {
+		return err
 	}
 
-	sort.Sort(StatusSlice(status))
-
-	return prettySummary(status)
+	if format := viper.GetString("format"); isPretty(format) {
+		err = prettySummary(status)
+	} else {
+		err = print(status, format, viper.GetString("filter"))
+	}
+	if err != nil {
+		return err
+	}
+	return statusError(status)
 }
 
 func init() {
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/ddn0/peanut/config"
+	"github.com/ddn0/peanut/logwriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var syncCmd = &cobra.Command{
+	Use:     "sync",
+	Aliases: []string{"clone"},
+	Short:   "clone repos in the directory file that are missing",
+	RunE:    runSync,
+}
+
+func clone(ctx context.Context, repo *config.Repo) error {
+	if dryRun() {
+		lw := logwriter.NewColorWriter(filepath.Base(repo.Path))
+		defer lw.Flush()
+		fmt.Fprintf(lw, "would clone %s into %s\n", repo.URL, repo.Path)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(repo.Path), 0777); err != nil {
+		return err
+	}
+
+	if viper.GetBool("verbose") {
+		lw := logwriter.NewColorWriter(filepath.Base(repo.Path))
+		fmt.Fprintf(lw, "cloning %s\n", repo.URL)
+		lw.Flush()
+	}
+
+	// Clone next to the target and rename on success so that a clone that is
+	// killed does not leave a partial repo that later syncs skip
+	tmp, err := ioutil.TempDir(filepath.Dir(repo.Path), "."+filepath.Base(repo.Path)+".clone")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	args := []string{"clone"}
+	if len(repo.Branch) > 0 {
+		args = append(args, "--branch", repo.Branch)
+	}
+	dst := filepath.Join(tmp, filepath.Base(repo.Path))
+	args = append(args, repo.URL, dst)
+	cmd := exec.Command("git", args...)
+	if err := runInRepo(ctx, repo.Path, cmd); err != nil {
+		return err
+	}
+	return os.Rename(dst, repo.Path)
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	cfg, err := readConf()
+	if err != nil {
+		return err
+	}
+
+	toClone := make(map[string]*config.Repo)
+	var dirs []string
+	for _, repo := range taggedRepos(cfg) {
+		if _, err := os.Stat(repo.Path); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if len(repo.URL) == 0 {
+			fmt.Fprintf(os.Stderr, "warn: no url to clone %q from\n", repo.Path)
+			continue
+		}
+		toClone[repo.Path] = repo
+		dirs = append(dirs, repo.Path)
+	}
+
+	results, err := doAllRepos(cmd.Context(), dirs, false, func(ctx context.Context, dir string) error {
+		return clone(ctx, toClone[dir])
+	})
+	if perr := printResults(results); perr != nil {
+		return perr
+	}
+	return err
+}
+
+func init() {
+	c := syncCmd
+
+	RootCmd.AddCommand(c)
+}
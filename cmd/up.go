@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ddn0/peanut/config"
+	"github.com/ddn0/peanut/git"
+	"github.com/ddn0/peanut/pdo"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var upCmd = &cobra.Command{
+	Use:   "up",
+	Short: "fetch, merge and summarize working directories",
+	Long: `Fetch each repo and merge it as soon as its fetch completes, then show
+the summary of all repos.
+
+By default, up merges as "merge --return --prune-local" does. It accepts
+the same flags as merge.`,
+	RunE: runUp,
+}
+
+// An Up is the result of up in machine-readable formats.
+type Up struct {
+	Results []MergeResult `json:"results"`
+	Status  []Status      `json:"status"`
+}
+
+func runUp(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	cfg, err := readConf()
+	if err != nil {
+		return err
+	}
+
+	gc := git.NewClient(nil)
+
+	selected, err := selectRepos(cmd.Context(), cfg)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	var repos []*config.Repo
+	var items []interface{}
+	for _, repo := range selected {
+		if seen[repo.Path] {
+			continue
+		}
+		seen[repo.Path] = true
+		repos = append(repos, repo)
+		items = append(items, repo)
+	}
+
+	var lock sync.Mutex
+	var results []MergeResult
+	addResult := func(r MergeResult) {
+		lock.Lock()
+		defer lock.Unlock()
+		results = append(results, r)
+	}
+
+	// Merge a repo as soon as its fetch completes rather than after all
+	// fetches
+	if err := pdo.DoAll(pdo.DoAllOpt{
+		GenFunc: func(ctx context.Context, item interface{}) ([]interface{}, error) {
+			repo := item.(*config.Repo)
+			if err := fetch(ctx, repo.Path); err != nil {
+				addResult(newMergeResult(repo.Path, MergeFailed, "", fmt.Errorf("error fetching: %s", err)))
+				return nil, nil
+			}
+			return []interface{}{repo}, nil
+		},
+		Func: func(ctx context.Context, item interface{}) error {
+			addResult(mergeRepo(ctx, gc, item.(*config.Repo)))
+			return nil
+		},
+		Items:           items,
+		Timeout:         viper.GetDuration("timeout"),
+		MaxConcurrent:   viper.GetInt("max-concurrent"),
+		ContinueOnError: true,
+		Context:         cmd.Context(),
+	}); err != nil {
+		// Only repos not started because of cancellation fail here
+		errs, ok := err.(pdo.Errors)
+		if !ok {
+			return err
+		}
+		for _, e := range errs {
+			results = append(results, newMergeResult(e.Item.(*config.Repo).Path, MergeFailed, "", e.Err))
+		}
+	}
+
+	status, err := readStatus(cmd.Context(), repos)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Repo < results[j].Repo
+	})
+	sort.Sort(StatusSlice(status))
+
+	if format := viper.GetString("format"); isPretty(format) {
+		prettyMergeResults(results)
+		err = prettySummary(status)
+	} else {
+		err = print(Up{Results: results, Status: status}, format, viper.GetString("filter"))
+	}
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, r := range results {
+		if !r.Ok {
+			failed += 1
+		}
+	}
+	if err := failedError(failed, len(results)); err != nil {
+		return err
+	}
+	return statusError(status)
+}
+
+func init() {
+	c := upCmd
+	flags := c.Flags()
+
+	RootCmd.AddCommand(c)
+	addMergeFlags(flags, true, true)
+}
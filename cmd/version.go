@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var versionCmd = &cobra.Command{
@@ -13,9 +14,21 @@ var versionCmd = &cobra.Command{
 	RunE:  runVersion,
 }
 
+type Version struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+}
+
 func runVersion(cmd *cobra.Command, args []string) error {
-	fmt.Printf("version: %s\n", strings.TrimSpace(version))
-	fmt.Printf("commit: %s\n", strings.TrimSpace(commit))
+	v := Version{
+		Version: strings.TrimSpace(version),
+		Commit:  strings.TrimSpace(commit),
+	}
+	if format := viper.GetString("format"); !isPretty(format) {
+		return print(v, format, viper.GetString("filter"))
+	}
+	fmt.Printf("version: %s\n", v.Version)
+	fmt.Printf("commit: %s\n", v.Commit)
 	return nil
 }
 
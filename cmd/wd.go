@@ -32,7 +32,10 @@ func runWd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	repos := cfg.RepoPaths()
+	repos, err := selectRepoPaths(cmd.Context(), cfg)
+	if err != nil {
+		return err
+	}
 	score := make(map[string]int)
 	for _, dir := range repos {
 		for _, arg := range args {
@@ -71,6 +74,9 @@ func runWd(cmd *cobra.Command, args []string) error {
 	})
 
 	for _, r := range repos {
+		if format := viper.GetString("format"); !isPretty(format) {
+			return print(Result{Repo: r, Ok: true}, format, viper.GetString("filter"))
+		}
 		fmt.Println(r)
 		return nil
 	}
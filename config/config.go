@@ -1,11 +1,68 @@
 package config
 
 type Config struct {
-	Repos []*Repo `json:"repos"`
+	Version int      `json:"version"`           // Schema version (see CurrentVersion)
+	Include []string `json:"include,omitempty"` // Other dir files to read (see LoadLayered)
+	Roots   []string `json:"roots,omitempty"`   // Directories to search for repos (see scan)
+	Repos   []*Repo  `json:"repos"`
 }
 
 type Repo struct {
-	Path string `json:"path"`
+	Path   string   `json:"path"`
+	URL    string   `json:"url,omitempty"`    // Remote to clone from if Path is missing
+	Branch string   `json:"branch,omitempty"` // Default branch of the repo
+	Group  string   `json:"group,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// HasTag returns true if the repo is labeled with tag.
+func (a Repo) HasTag(tag string) bool {
+	for _, t := range a.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTags adds tags not already present on the repo.
+func (a *Repo) AddTags(tags ...string) {
+	for _, t := range tags {
+		if len(t) == 0 || a.HasTag(t) {
+			continue
+		}
+		a.Tags = append(a.Tags, t)
+	}
+}
+
+// Matches returns true if the repo belongs to any of groups and has any of
+// tags. An empty groups or tags list matches every repo.
+func (a Repo) Matches(groups, tags []string) bool {
+	if len(groups) > 0 {
+		found := false
+		for _, g := range groups {
+			if a.Group == g {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(tags) > 0 {
+		found := false
+		for _, t := range tags {
+			if a.HasTag(t) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 func (a Config) RepoPaths() (ret []string) {
@@ -14,3 +71,35 @@ func (a Config) RepoPaths() (ret []string) {
 	}
 	return
 }
+
+// Select returns the repos that match groups and tags.
+func (a Config) Select(groups, tags []string) (ret []*Repo) {
+	for _, c := range a.Repos {
+		if c.Matches(groups, tags) {
+			ret = append(ret, c)
+		}
+	}
+	return
+}
+
+// Find returns the repo with the given path or nil.
+func (a Config) Find(path string) *Repo {
+	for _, c := range a.Repos {
+		if c.Path == path {
+			return c
+		}
+	}
+	return nil
+}
+
+// Remove removes the repo with the given path and returns true if it was
+// found.
+func (a *Config) Remove(path string) bool {
+	for idx, c := range a.Repos {
+		if c.Path == path {
+			a.Repos = append(a.Repos[:idx], a.Repos[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
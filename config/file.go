@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+)
+
+// Number of previous versions of a config file to keep as file.bak.1 (most
+// recent) through file.bak.N
+const backups = 3
+
+// Load reads the config in file, migrating it to the current version. A
+// missing file is an empty config. Invalid configs return ValidationErrors.
+func Load(file string) (*Config, error) {
+	bs, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return &Config{Version: CurrentVersion}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parse(file, bs)
+}
+
+// rotateBackups shifts the backups of file and saves bs as the most recent
+// one.
+func rotateBackups(file string, bs []byte) error {
+	for n := backups - 1; n > 0; n -= 1 {
+		older := fmt.Sprintf("%s.bak.%d", file, n)
+		if _, err := os.Stat(older); err != nil {
+			continue
+		}
+		if err := os.Rename(older, fmt.Sprintf("%s.bak.%d", file, n+1)); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(file+".bak.1", bs, 0666)
+}
+
+// writeAtomic replaces file with bs by writing a temporary file and renaming
+// it so that readers never see a partially written file.
+func writeAtomic(file string, bs []byte) error {
+	mode := os.FileMode(0666)
+	if fi, err := os.Stat(file); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Update reads the config in file, calls fn to modify it and writes the
+// result. An advisory lock on file is held throughout so that concurrent
+// updates are not lost. The previous contents of file are kept as a backup.
+// If fn returns an error or does not change the config, file is not written.
+//
+// Only file is updated, not the files it includes. Relative paths are
+// absolute while fn runs and are written back as they were.
+func Update(file string, fn func(*Config) error) error {
+	unlock, err := lock(file)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return update(file, fn, true)
+}
+
+// DryUpdate reads the config in file and calls fn with it as Update does but
+// neither locks nor writes file.
+func DryUpdate(file string, fn func(*Config) error) error {
+	return update(file, fn, false)
+}
+
+func update(file string, fn func(*Config) error, write bool) error {
+	old, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	cfg, err := parse(file, old)
+	if err != nil {
+		return err
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	orig := cfg.resolveRepoPaths(filepath.Dir(abs))
+	if err := fn(cfg); err != nil {
+		return err
+	}
+	for r, p := range orig {
+		if r.Path == resolvePath(filepath.Dir(abs), p) {
+			r.Path = p
+		}
+	}
+	if !write {
+		return nil
+	}
+
+	bs, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(bs, old) {
+		return nil
+	}
+	if len(old) > 0 {
+		if err := rotateBackups(file, old); err != nil {
+			return err
+		}
+	}
+	return writeAtomic(file, bs)
+}
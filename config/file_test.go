@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peanut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "dir")
+
+	// Like many add-dir commands run at once
+	num := 50
+	var wg sync.WaitGroup
+	wg.Add(num)
+	for idx := 0; idx < num; idx += 1 {
+		go func(idx int) {
+			defer wg.Done()
+			err := Update(file, func(cfg *Config) error {
+				cfg.Repos = append(cfg.Repos, &Repo{Path: fmt.Sprintf("/repo/%d", idx)})
+				return nil
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}(idx)
+	}
+	wg.Wait()
+
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cfg.Repos) != num {
+		t.Errorf("expected %d repos but found %d", num, len(cfg.Repos))
+	}
+	for idx := 0; idx < num; idx += 1 {
+		if cfg.Find(fmt.Sprintf("/repo/%d", idx)) == nil {
+			t.Errorf("expected /repo/%d", idx)
+		}
+	}
+
+	backup, err := Load(file + ".bak.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(backup.Repos) != num-1 {
+		t.Errorf("expected backup with %d repos but found %d", num-1, len(backup.Repos))
+	}
+	if _, err := os.Stat(file + fmt.Sprintf(".bak.%d", backups+1)); !os.IsNotExist(err) {
+		t.Errorf("expected at most %d backups", backups)
+	}
+}
+
+func TestUpdateError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peanut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "dir")
+
+	if err := ioutil.WriteFile(file, []byte("repos:\n- path: /a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	err = Update(file, func(cfg *Config) error {
+		cfg.Repos = nil
+		return fmt.Errorf("failed")
+	})
+	if err == nil {
+		t.Errorf("expected error")
+	}
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Find("/a") == nil {
+		t.Errorf("expected file to be unchanged")
+	}
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of a project-local dir file (see FindProjectFile)
+const ProjectFile = ".peanut.yaml"
+
+// FindProjectFile returns the nearest ProjectFile in dir or its parents or ""
+// if there is none.
+func FindProjectFile(dir string) string {
+	for {
+		fn := filepath.Join(dir, ProjectFile)
+		if fi, err := os.Stat(fn); err == nil && !fi.IsDir() {
+			return fn
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// resolvePath returns path relative to dir unless it is absolute. A leading
+// ~/ is replaced with the home directory.
+func resolvePath(dir, path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
+
+// resolveRepoPaths makes the paths of repos relative to dir absolute. It
+// returns the original paths of the repos that were changed.
+func (a *Config) resolveRepoPaths(dir string) map[*Repo]string {
+	orig := make(map[*Repo]string)
+	for _, r := range a.Repos {
+		if p := resolvePath(dir, r.Path); p != r.Path {
+			orig[r] = r.Path
+			r.Path = p
+		}
+	}
+	return orig
+}
+
+// Merge adds the repos and roots of other to the config. For a repo in both,
+// the URL, branch and group of other take precedence and tags are combined.
+func (a *Config) Merge(other *Config) {
+	for _, r := range other.Repos {
+		repo := a.Find(r.Path)
+		if repo == nil {
+			repo = &Repo{Path: r.Path}
+			a.Repos = append(a.Repos, repo)
+		}
+		if len(r.URL) > 0 {
+			repo.URL = r.URL
+		}
+		if len(r.Branch) > 0 {
+			repo.Branch = r.Branch
+		}
+		if len(r.Group) > 0 {
+			repo.Group = r.Group
+		}
+		repo.AddTags(r.Tags...)
+	}
+
+	seen := make(map[string]bool)
+	for _, r := range a.Roots {
+		seen[r] = true
+	}
+	for _, r := range other.Roots {
+		if !seen[r] {
+			seen[r] = true
+			a.Roots = append(a.Roots, r)
+		}
+	}
+}
+
+// LoadLayered reads the config in file and the files it includes. Relative
+// paths are relative to the directory of the file they appear in. Entries in
+// a file take precedence over entries in the files it includes, and later
+// includes take precedence over earlier ones. Unlike Load, file and the files
+// it includes must exist.
+func LoadLayered(file string) (*Config, error) {
+	return loadLayered(file, make(map[string]bool))
+}
+
+func loadLayered(file string, including map[string]bool) (*Config, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if including[abs] {
+		return nil, fmt.Errorf("%s: include cycle", file)
+	}
+	including[abs] = true
+	defer delete(including, abs)
+
+	if _, err := os.Stat(abs); err != nil {
+		return nil, err
+	}
+	cfg, err := Load(abs)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(abs)
+	cfg.resolveRepoPaths(dir)
+	for idx, r := range cfg.Roots {
+		cfg.Roots[idx] = resolvePath(dir, r)
+	}
+
+	ret := &Config{Version: CurrentVersion}
+	for _, inc := range cfg.Include {
+		fn := resolvePath(dir, inc)
+		if _, err := os.Stat(fn); err != nil {
+			return nil, fmt.Errorf("%s: include %s: %s", file, inc, err)
+		}
+		icfg, err := loadLayered(fn, including)
+		if err != nil {
+			return nil, err
+		}
+		ret.Merge(icfg)
+	}
+	ret.Merge(cfg)
+	return ret, nil
+}
+
+// ValidateLayered returns the problems with the config in file and the files
+// it includes as ValidationErrors. Unlike LoadLayered, it does not stop at the
+// first invalid file.
+func ValidateLayered(file string) error {
+	if _, err := os.Stat(file); err != nil {
+		return err
+	}
+	var errs ValidationErrors
+	validateLayered(file, make(map[string]bool), make(map[string]bool), &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateLayered(file string, including, done map[string]bool, errs *ValidationErrors) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		*errs = append(*errs, ValidationError{File: file, Msg: err.Error()})
+		return
+	}
+	if done[abs] {
+		return
+	}
+	including[abs] = true
+	defer delete(including, abs)
+	done[abs] = true
+
+	bs, err := ioutil.ReadFile(abs)
+	if err != nil {
+		*errs = append(*errs, ValidationError{File: file, Msg: err.Error()})
+		return
+	}
+	if err := Validate(abs, bs); err != nil {
+		if verrs, ok := err.(ValidationErrors); ok {
+			*errs = append(*errs, verrs...)
+		} else {
+			*errs = append(*errs, ValidationError{File: file, Msg: err.Error()})
+		}
+	}
+
+	// Check the files included by an invalid file too
+	dir := filepath.Dir(abs)
+	for _, inc := range includes(bs) {
+		fn := resolvePath(dir, inc)
+		if _, err := os.Stat(fn); err != nil {
+			*errs = append(*errs, ValidationError{File: abs, Msg: fmt.Sprintf("include %s: %s", inc, err)})
+			continue
+		}
+		if including[fn] {
+			*errs = append(*errs, ValidationError{File: abs, Msg: fmt.Sprintf("include %s: include cycle", inc)})
+			continue
+		}
+		validateLayered(fn, including, done, errs)
+	}
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadLayered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peanut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"ws/.peanut.yaml": `
+include:
+- team.yaml
+- ../personal
+repos:
+- path: a
+  group: mine
+`,
+		"ws/team.yaml": `
+roots:
+- .
+repos:
+- path: a
+  url: team-url
+  group: team
+  tags: [team]
+- path: b
+`,
+		"personal": `
+repos:
+- path: ws/a
+  branch: main
+  tags: [personal]
+`,
+	})
+
+	ws := filepath.Join(dir, "ws")
+	if fn := FindProjectFile(filepath.Join(ws, "sub", "dir")); fn != filepath.Join(ws, ProjectFile) {
+		t.Errorf("expected project file in %s but found %q", ws, fn)
+	}
+
+	cfg, err := LoadLayered(filepath.Join(ws, ProjectFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []*Repo{
+		{Path: filepath.Join(ws, "a"), URL: "team-url", Branch: "main", Group: "mine", Tags: []string{"team", "personal"}},
+		{Path: filepath.Join(ws, "b")},
+	}
+	if !reflect.DeepEqual(cfg.Repos, expected) {
+		t.Errorf("expected %+v but found %+v", expected, cfg.Repos)
+	}
+	if !reflect.DeepEqual(cfg.Roots, []string{ws}) {
+		t.Errorf("expected roots [%s] but found %v", ws, cfg.Roots)
+	}
+}
+
+func TestLoadLayeredErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peanut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"cycle1":  "include: [cycle2]\n",
+		"cycle2":  "include: [cycle1]\n",
+		"missing": "include: [nonexistent]\n",
+	})
+	for _, name := range []string{"cycle1", "missing"} {
+		if _, err := LoadLayered(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestUpdateRelative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peanut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"dir": "repos:\n- path: a\n"})
+
+	file := filepath.Join(dir, "dir")
+	err = Update(file, func(cfg *Config) error {
+		if cfg.Find(filepath.Join(dir, "a")) == nil {
+			t.Errorf("expected relative path to be resolved")
+		}
+		cfg.Repos = append(cfg.Repos, &Repo{Path: "/b"})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Find("a") == nil || cfg.Find("/b") == nil {
+		t.Errorf("expected repos a and /b but found %+v", cfg.Repos)
+	}
+}
+
+func TestDryUpdateRelative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peanut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"dir": "repos:\n- path: a\n"})
+
+	file := filepath.Join(dir, "dir")
+	err = DryUpdate(file, func(cfg *Config) error {
+		if cfg.Find(filepath.Join(dir, "a")) == nil {
+			t.Errorf("expected relative path to be resolved")
+		}
+		cfg.Repos = append(cfg.Repos, &Repo{Path: "/b"})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Find("/b") != nil {
+		t.Errorf("expected file to be unchanged but found %+v", cfg.Repos)
+	}
+}
+
+func TestValidateLayered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peanut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"top.yaml": "include: [a.yaml, b.yaml, missing.yaml]\ncolour: blue\n",
+		"a.yaml":   "repos:\n- tags: [x]\n",
+		"b.yaml":   "include: [top.yaml]\nrepos: null\n",
+	})
+
+	err = ValidateLayered(filepath.Join(dir, "top.yaml"))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors but found %v", err)
+	}
+	var found []string
+	for _, e := range errs {
+		rel, _ := filepath.Rel(dir, e.File)
+		found = append(found, fmt.Sprintf("%s:%d", rel, e.Line))
+	}
+	expected := []string{"top.yaml:2", "a.yaml:2", "b.yaml:0", "top.yaml:0"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %q but found %q (%s)", expected, found, errs)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package config
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock takes an exclusive advisory lock for file, waiting for other holders
+// to release it, and returns a function to release the lock.
+func lock(file string) (func(), error) {
+	f, err := os.OpenFile(file+".lock", os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
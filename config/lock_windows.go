@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Maximum time to wait for another process to release a lock
+const lockTimeout = 10 * time.Second
+
+// lock takes an exclusive lock for file by creating file.lock, waiting for
+// other holders to release it, and returns a function to release the lock.
+func lock(file string) (func(), error) {
+	name := file + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s; remove it if no other peanut is running", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Version of the config schema written by this version of peanut
+const CurrentVersion = 1
+
+// migrations[v] upgrades a document of version v to version v+1. When Config
+// gains or renames fields, bump CurrentVersion and add a migration here so
+// that older files keep working.
+var migrations = []func(root *yamlv3.Node) error{
+	// Version 0 files predate the version field and otherwise have the same
+	// schema as version 1
+	func(root *yamlv3.Node) error { return nil },
+}
+
+// A ValidationError is a problem at a position in a config file.
+type ValidationError struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"` // 1-based line of the problem or 0 if unknown
+	Column int    `json:"column"`
+	Msg    string `json:"msg"`
+}
+
+func (a ValidationError) Error() string {
+	if a.Line == 0 {
+		return fmt.Sprintf("%s: %s", a.File, a.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", a.File, a.Line, a.Column, a.Msg)
+}
+
+// ValidationErrors are all the problems found in a config file.
+type ValidationErrors []ValidationError
+
+func (a ValidationErrors) Error() string {
+	var msgs []string
+	for _, e := range a {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// describe returns a brief description of n for error messages.
+func describe(n *yamlv3.Node) string {
+	switch n.Kind {
+	case yamlv3.SequenceNode:
+		return "a list"
+	case yamlv3.MappingNode:
+		return "a mapping"
+	default:
+		return fmt.Sprintf("%s %q", strings.TrimPrefix(n.Tag, "!!"), n.Value)
+	}
+}
+
+type validator struct {
+	file string
+	errs ValidationErrors
+}
+
+func (a *validator) errorf(n *yamlv3.Node, format string, args ...interface{}) {
+	a.errs = append(a.errs, ValidationError{
+		File:   a.file,
+		Line:   n.Line,
+		Column: n.Column,
+		Msg:    fmt.Sprintf(format, args...),
+	})
+}
+
+func (a *validator) str(n *yamlv3.Node) {
+	if n.Kind != yamlv3.ScalarNode || (n.Tag != "!!str" && n.Tag != "!!null") {
+		a.errorf(n, "expected a string but found %s", describe(n))
+	}
+}
+
+// isNull returns true if n is null, e.g., a key without a value. Null lists
+// are empty lists.
+func isNull(n *yamlv3.Node) bool {
+	return n.Kind == yamlv3.ScalarNode && n.Tag == "!!null"
+}
+
+func (a *validator) strs(n *yamlv3.Node) {
+	if isNull(n) {
+		return
+	}
+	if n.Kind != yamlv3.SequenceNode {
+		a.errorf(n, "expected a list of strings but found %s", describe(n))
+		return
+	}
+	for _, c := range n.Content {
+		a.str(c)
+	}
+}
+
+func (a *validator) version(n *yamlv3.Node) {
+	if n.Kind != yamlv3.ScalarNode || n.Tag != "!!int" {
+		a.errorf(n, "expected a version number but found %s", describe(n))
+		return
+	}
+	if v, _ := strconv.Atoi(n.Value); v < 0 || v > CurrentVersion {
+		a.errorf(n, "unsupported version %s; this peanut supports up to version %d", n.Value, CurrentVersion)
+	}
+}
+
+// mapping checks that n is a mapping whose keys are in fields and checks each
+// value with the function for its key. It returns the values by key.
+func (a *validator) mapping(n *yamlv3.Node, what string, fields map[string]func(*yamlv3.Node)) map[string]*yamlv3.Node {
+	if n.Kind != yamlv3.MappingNode {
+		a.errorf(n, "expected %s but found %s", what, describe(n))
+		return nil
+	}
+	ret := make(map[string]*yamlv3.Node)
+	for idx := 0; idx+1 < len(n.Content); idx += 2 {
+		k, v := n.Content[idx], n.Content[idx+1]
+		check, ok := fields[k.Value]
+		if !ok {
+			a.errorf(k, "unknown key %q in %s", k.Value, what)
+			continue
+		}
+		if _, ok := ret[k.Value]; ok {
+			a.errorf(k, "duplicate key %q", k.Value)
+		}
+		ret[k.Value] = v
+		check(v)
+	}
+	return ret
+}
+
+func (a *validator) repos(n *yamlv3.Node) {
+	if isNull(n) {
+		return
+	}
+	if n.Kind != yamlv3.SequenceNode {
+		a.errorf(n, "expected a list of repos but found %s", describe(n))
+		return
+	}
+	seen := make(map[string]*yamlv3.Node)
+	for _, c := range n.Content {
+		fields := a.mapping(c, "repo", map[string]func(*yamlv3.Node){
+			"path":   a.str,
+			"url":    a.str,
+			"branch": a.str,
+			"group":  a.str,
+			"tags":   a.strs,
+		})
+		if fields == nil {
+			continue
+		}
+		p, ok := fields["path"]
+		switch {
+		case !ok:
+			a.errorf(c, "repo has no path")
+		case len(p.Value) == 0:
+			a.errorf(p, "repo has an empty path")
+		case seen[p.Value] != nil:
+			a.errorf(p, "duplicate repo %q; first at line %d", p.Value, seen[p.Value].Line)
+		default:
+			seen[p.Value] = p
+		}
+	}
+}
+
+func (a *validator) config(n *yamlv3.Node) {
+	if isNull(n) {
+		return
+	}
+	a.mapping(n, "config", map[string]func(*yamlv3.Node){
+		"version": a.version,
+		"include": a.strs,
+		"roots":   a.strs,
+		"repos":   a.repos,
+	})
+}
+
+// docVersion returns the version of the config in root. Files without a
+// version are version 0. Invalid versions are reported by validation.
+func docVersion(root *yamlv3.Node) int {
+	if root.Kind != yamlv3.MappingNode {
+		return 0
+	}
+	for idx := 0; idx+1 < len(root.Content); idx += 2 {
+		if root.Content[idx].Value == "version" {
+			v, _ := strconv.Atoi(root.Content[idx+1].Value)
+			return v
+		}
+	}
+	return 0
+}
+
+// parse migrates the config in bs, read from file, to the current version and
+// validates it. Problems are returned as ValidationErrors.
+func parse(file string, bs []byte) (*Config, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(bs, &doc); err != nil {
+		return nil, ValidationErrors{{File: file, Msg: err.Error()}}
+	}
+	if len(doc.Content) == 0 {
+		return &Config{Version: CurrentVersion}, nil
+	}
+	root := doc.Content[0]
+
+	for v := docVersion(root); v >= 0 && v < CurrentVersion; v += 1 {
+		if err := migrations[v](root); err != nil {
+			return nil, fmt.Errorf("%s: error migrating from version %d: %s", file, v, err)
+		}
+	}
+
+	va := validator{file: file}
+	va.config(root)
+	if len(va.errs) > 0 {
+		return nil, va.errs
+	}
+
+	// Decode through JSON to use the json tags of Config
+	var v interface{}
+	if err := root.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(js, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	cfg.Version = CurrentVersion
+	return &cfg, nil
+}
+
+// includes returns the files included by the config in bs, even if the
+// config is otherwise invalid.
+func includes(bs []byte) []string {
+	var cfg struct {
+		Include []string `yaml:"include"`
+	}
+	if err := yamlv3.Unmarshal(bs, &cfg); err != nil {
+		return nil
+	}
+	return cfg.Include
+}
+
+// Validate returns the problems with the config in bs, read from file, as
+// ValidationErrors.
+func Validate(file string, bs []byte) error {
+	_, err := parse(file, bs)
+	return err
+}
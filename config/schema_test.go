@@ -0,0 +1,86 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	bs := []byte(`
+roots: [/src]
+repos:
+- path: /src/a
+  tags: [x]
+`)
+	cfg, err := parse("dir", bs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := &Config{
+		Version: CurrentVersion,
+		Roots:   []string{"/src"},
+		Repos:   []*Repo{{Path: "/src/a", Tags: []string{"x"}}},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("expected %+v but found %+v", expected, cfg)
+	}
+
+	if cfg, err := parse("dir", nil); err != nil || cfg.Version != CurrentVersion {
+		t.Errorf("expected empty config but found %+v, %v", cfg, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	lines := []string{
+		"version: 1",
+		"repos:",
+		"- path: /a",
+		"  tag: [x]",
+		"- path: /a",
+		"- group: g",
+		"- path: /b",
+		"  tags: 3",
+		"colour: blue",
+	}
+	err := Validate("dir", []byte(strings.Join(lines, "\n")))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors but found %v", err)
+	}
+
+	expected := []string{
+		`dir:4:3: unknown key "tag" in repo`,
+		`dir:5:9: duplicate repo "/a"; first at line 3`,
+		`dir:6:3: repo has no path`,
+		`dir:8:9: expected a list of strings but found int "3"`,
+		`dir:9:1: unknown key "colour" in config`,
+	}
+	var found []string
+	for _, e := range errs {
+		found = append(found, e.Error())
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %q but found %q", expected, found)
+	}
+
+	if err := Validate("dir", []byte("version: 99\n")); err == nil {
+		t.Errorf("expected error for unsupported version")
+	}
+	if err := Validate("dir", []byte("repos: [\n")); err == nil {
+		t.Errorf("expected syntax error")
+	}
+}
+
+func TestParseNullLists(t *testing.T) {
+	// Older versions wrote dir files without repos as "repos: null"
+	for _, s := range []string{
+		"repos: null\n",
+		"repos:\ninclude:\nroots:\n",
+		"repos:\n- path: /a\n  tags:\n",
+	} {
+		if _, err := parse("dir", []byte(s)); err != nil {
+			t.Errorf("%q: unexpected error: %s", s, err)
+		}
+	}
+}
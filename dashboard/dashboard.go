@@ -0,0 +1,208 @@
+// Live display of the progress of operations across repos. Each repo has one
+// line that is redrawn in place on a terminal.
+package dashboard
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddn0/peanut/logwriter"
+	"github.com/mgutz/ansi"
+)
+
+const (
+	Pending = "pending"
+	Running = "running"
+	Ok      = "ok"
+	Failed  = "failed"
+)
+
+type row struct {
+	name     string
+	state    string
+	start    time.Time
+	end      time.Time
+	lastLine string // Last line of stderr
+	err      error
+}
+
+type Dashboard struct {
+	out   io.Writer
+	rows  []*row
+	index map[string]*row
+	drawn int          // Number of lines drawn by last redraw
+	msgs  bytes.Buffer // Messages to print above the next redraw
+	lock  sync.Mutex
+	stop  chan bool
+	done  chan bool
+	now   func() time.Time
+}
+
+// IsTerminal returns true if f is a terminal.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// New returns a dashboard with a pending row for each key. Names are
+// displayed instead of keys.
+func New(out io.Writer, keys, names []string) *Dashboard {
+	d := &Dashboard{
+		out:   out,
+		index: make(map[string]*row),
+		now:   time.Now,
+	}
+	for idx, k := range keys {
+		r := &row{name: names[idx], state: Pending}
+		d.rows = append(d.rows, r)
+		d.index[k] = r
+	}
+	return d
+}
+
+// Start redraws the dashboard every interval until Stop is called.
+func (a *Dashboard) Start(interval time.Duration) {
+	a.stop = make(chan bool)
+	a.done = make(chan bool)
+	go func() {
+		defer close(a.done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			a.Redraw()
+			select {
+			case <-t.C:
+			case <-a.stop:
+				a.Redraw()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops redrawing after a final redraw.
+func (a *Dashboard) Stop() {
+	close(a.stop)
+	<-a.done
+}
+
+// Started marks key as running.
+func (a *Dashboard) Started(key string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if r, ok := a.index[key]; ok {
+		r.state = Running
+		r.start = a.now()
+	}
+}
+
+// Finished marks key as ok if err is nil or failed otherwise.
+func (a *Dashboard) Finished(key string, err error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if r, ok := a.index[key]; ok {
+		r.state = Ok
+		if err != nil {
+			r.state = Failed
+			r.err = err
+		}
+		r.end = a.now()
+	}
+}
+
+type lineWriter struct {
+	d   *Dashboard
+	key string
+}
+
+// Receives one line at a time from log.Logger
+func (a *lineWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSpace(string(p))
+	if len(line) == 0 {
+		return len(p), nil
+	}
+	a.d.lock.Lock()
+	defer a.d.lock.Unlock()
+	if r, ok := a.d.index[a.key]; ok {
+		r.lastLine = line
+	}
+	return len(p), nil
+}
+
+// Writer returns a writer that records the last line written to it for key.
+func (a *Dashboard) Writer(key string) *logwriter.LogWriter {
+	return logwriter.New(log.New(&lineWriter{d: a, key: key}, "", 0), nil)
+}
+
+// Write prints p above the dashboard at the next redraw so that messages
+// logged while the dashboard is shown are not drawn over.
+func (a *Dashboard) Write(p []byte) (int, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.msgs.Write(p)
+}
+
+func (a *Dashboard) render() []string {
+	var width int
+	for _, r := range a.rows {
+		if n := len(r.name); n > width {
+			width = n
+		}
+	}
+
+	var lines []string
+	for _, r := range a.rows {
+		name := fmt.Sprintf("%-*s", width, r.name)
+		var desc string
+		switch r.state {
+		case Pending:
+			desc = ansi.Color(Pending, "white")
+		case Running:
+			elapsed := a.now().Sub(r.start) / time.Second * time.Second
+			desc = ansi.Color(fmt.Sprintf("%s %s", Running, elapsed), "yellow")
+		case Ok:
+			desc = ansi.Color(Ok, "green")
+		case Failed:
+			desc = ansi.Color(fmt.Sprintf("%s (%s)", Failed, r.err), "red")
+			if len(r.lastLine) > 0 {
+				desc += " " + r.lastLine
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", ansi.Color(name, "cyan"), desc))
+	}
+	return lines
+}
+
+// Redraw draws the dashboard over the previous drawing.
+func (a *Dashboard) Redraw() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var buf bytes.Buffer
+	if a.drawn > 0 {
+		// Move to start of previous drawing
+		fmt.Fprintf(&buf, "\x1b[%dA", a.drawn)
+	}
+	if a.msgs.Len() > 0 {
+		for _, l := range strings.Split(strings.TrimSuffix(a.msgs.String(), "\n"), "\n") {
+			fmt.Fprintf(&buf, "\x1b[2K\r%s\n", l)
+		}
+		a.msgs.Reset()
+	}
+	lines := a.render()
+	for _, l := range lines {
+		// Clear line then write
+		fmt.Fprintf(&buf, "\x1b[2K\r%s\n", l)
+	}
+	a.drawn = len(lines)
+	a.out.Write(buf.Bytes())
+}
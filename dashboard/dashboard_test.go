@@ -0,0 +1,83 @@
+package dashboard
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	var buf bytes.Buffer
+	d := New(&buf, []string{"/a", "/bb", "/c", "/d"}, []string{"a", "bb", "c", "d"})
+	now := time.Unix(0, 0)
+	d.now = func() time.Time { return now }
+
+	d.Started("/bb")
+	d.Started("/c")
+	d.Started("/d")
+	w := d.Writer("/d")
+	w.Write([]byte("first\nlast\n"))
+	w.Flush()
+	now = now.Add(3 * time.Second)
+	d.Finished("/c", nil)
+	d.Finished("/d", errors.New("exit status 1"))
+
+	expected := []string{
+		"a  pending",
+		"bb running 3s",
+		"c  ok",
+		"d  failed (exit status 1) last",
+	}
+	found := d.render()
+	for idx := range found {
+		found[idx] = stripColor(found[idx])
+	}
+	if strings.Join(found, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q but found %q", expected, found)
+	}
+
+	d.Redraw()
+	d.Redraw()
+	if n := strings.Count(buf.String(), "\x1b[4A"); n != 1 {
+		t.Errorf("expected one cursor move but found %d in %q", n, buf.String())
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	d := New(&buf, []string{"/a"}, []string{"a"})
+
+	d.Redraw()
+	d.Write([]byte("warn: one\nwarn: two"))
+	d.Redraw()
+	d.Redraw()
+
+	// Messages are printed once, over the previous drawing and above the rows
+	draws := strings.Split(buf.String(), "\x1b[1A")
+	if len(draws) != 3 {
+		t.Fatalf("expected three drawings but found %q", buf.String())
+	}
+	expected := "\x1b[2K\rwarn: one\n\x1b[2K\rwarn: two\n\x1b[2K\r"
+	if !strings.HasPrefix(draws[1], expected) {
+		t.Errorf("expected %q to start with %q", draws[1], expected)
+	}
+	if strings.Contains(draws[2], "warn") {
+		t.Errorf("expected messages to be printed once but found %q", draws[2])
+	}
+}
+
+func stripColor(s string) string {
+	var out strings.Builder
+	for idx := 0; idx < len(s); idx += 1 {
+		if s[idx] == '\x1b' {
+			for idx < len(s) && s[idx] != 'm' {
+				idx += 1
+			}
+			continue
+		}
+		out.WriteByte(s[idx])
+	}
+	return out.String()
+}
@@ -0,0 +1,110 @@
+// Structured events describing processes run in repos. Events are written as
+// newline-delimited JSON so that they can be consumed by log pipelines.
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ddn0/peanut/logwriter"
+)
+
+const (
+	Start  = "start"  // Process started
+	Output = "output" // Line of process output
+	Exit   = "exit"   // Process exited
+)
+
+type Event struct {
+	Repo       string    `json:"repo"`
+	Type       string    `json:"type"`
+	Stream     string    `json:"stream,omitempty"` // stdout or stderr for Output events
+	Line       string    `json:"line"`             // For Output events; blank lines are kept
+	Ts         time.Time `json:"ts"`
+	ExitCode   *int      `json:"exit_code,omitempty"`   // For Exit events
+	DurationMs *int64    `json:"duration_ms,omitempty"` // For Exit events
+	Error      string    `json:"error,omitempty"`       // For Exit events
+}
+
+// An Emitter writes events to an io.Writer. It is safe for concurrent use.
+type Emitter struct {
+	out  io.Writer
+	lock sync.Mutex
+	now  func() time.Time
+}
+
+func New(out io.Writer) *Emitter {
+	return &Emitter{out: out, now: time.Now}
+}
+
+// Emit writes a single event, filling in its timestamp if not set.
+func (a *Emitter) Emit(e Event) error {
+	if e.Ts.IsZero() {
+		e.Ts = a.now()
+	}
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	_, err = a.out.Write(bs)
+	return err
+}
+
+// Started emits a Start event for repo.
+func (a *Emitter) Started(repo string) error {
+	return a.Emit(Event{Repo: repo, Type: Start})
+}
+
+// Exited emits an Exit event for repo.
+func (a *Emitter) Exited(repo string, exitCode int, duration time.Duration, err error) error {
+	ms := int64(duration / time.Millisecond)
+	e := Event{
+		Repo:       repo,
+		Type:       Exit,
+		ExitCode:   &exitCode,
+		DurationMs: &ms,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return a.Emit(e)
+}
+
+type lineWriter struct {
+	emitter *Emitter
+	repo    string
+	stream  string
+}
+
+// Receives one line at a time from log.Logger
+func (a *lineWriter) Write(p []byte) (int, error) {
+	line := string(bytes.TrimSuffix(p, []byte{'\n'}))
+	if err := a.emitter.Emit(Event{
+		Repo:   a.repo,
+		Type:   Output,
+		Stream: a.stream,
+		Line:   line,
+	}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Writer returns a writer that emits an Output event for each line written to
+// it. Call Flush to emit any trailing partial line.
+func (a *Emitter) Writer(repo, stream string) *logwriter.LogWriter {
+	w := &lineWriter{
+		emitter: a,
+		repo:    repo,
+		stream:  stream,
+	}
+	return logwriter.New(log.New(w, "", 0), nil)
+}
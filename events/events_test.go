@@ -0,0 +1,73 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	e := New(&buf)
+	ts := time.Unix(0, 0).UTC()
+	e.now = func() time.Time { return ts }
+
+	w := e.Writer("repo", "stdout")
+	if _, err := w.Write([]byte("one\ntw")); err != nil {
+		t.Fatalf("error writing: %s", err)
+	}
+	if _, err := w.Write([]byte("o")); err != nil {
+		t.Fatalf("error writing: %s", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := e.Exited("repo", 2, time.Second, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var found []Event
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var ev Event
+		if err := dec.Decode(&ev); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		found = append(found, ev)
+	}
+
+	if len(found) != 3 {
+		t.Fatalf("expected 3 events but found %d", len(found))
+	}
+	for i, line := range []string{"one", "two"} {
+		if ev := found[i]; ev.Type != Output || ev.Stream != "stdout" || ev.Line != line || !ev.Ts.Equal(ts) {
+			t.Errorf("unexpected event %+v", ev)
+		}
+	}
+	if ev := found[2]; ev.Type != Exit || *ev.ExitCode != 2 || *ev.DurationMs != 1000 {
+		t.Errorf("unexpected event %+v", ev)
+	}
+}
+
+func TestWriterEmptyLine(t *testing.T) {
+	var buf bytes.Buffer
+	e := New(&buf)
+
+	w := e.Writer("repo", "stdout")
+	if _, err := w.Write([]byte("one\n\ntwo\n")); err != nil {
+		t.Fatalf("error writing: %s", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 events but found %q", lines)
+	}
+	if !strings.Contains(lines[1], `"line":""`) {
+		t.Errorf("expected empty line in %s", lines[1])
+	}
+}
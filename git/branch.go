@@ -0,0 +1,95 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Branch is a local branch.
+type Branch struct {
+	Name       string    `json:"name"`
+	Sha        string    `json:"sha"`
+	Head       bool      `json:"head"`               // Checked out in the work tree
+	Upstream   string    `json:"upstream,omitempty"` // Upstream branch name, if any
+	Gone       bool      `json:"gone"`               // Upstream is set but no longer exists
+	Ahead      int       `json:"ahead"`              // Commits ahead of upstream
+	Behind     int       `json:"behind"`             // Commits behind upstream
+	CommitDate time.Time `json:"commit_date"`        // Committer date of the last commit
+}
+
+// Fields of each branch, separated by NUL
+const branchFormat = "%(HEAD)%00%(refname:short)%00%(objectname)%00%(upstream:short)%00%(upstream:track,nobracket)%00%(committerdate:unix)"
+
+// parseTrack parses the upstream:track,nobracket field of for-each-ref, e.g.,
+// "ahead 1, behind 2" or "gone".
+func parseTrack(s string, b *Branch) error {
+	if s == "gone" {
+		b.Gone = true
+		return nil
+	}
+	for _, part := range strings.Split(s, ", ") {
+		if len(part) == 0 {
+			continue
+		}
+		fields := strings.Fields(part)
+		if len(fields) != 2 {
+			return fmt.Errorf("unexpected track %q", s)
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("unexpected track %q", s)
+		}
+		switch fields[0] {
+		case "ahead":
+			b.Ahead = n
+		case "behind":
+			b.Behind = n
+		default:
+			return fmt.Errorf("unexpected track %q", s)
+		}
+	}
+	return nil
+}
+
+func parseBranches(bs []byte) ([]Branch, error) {
+	var ret []Branch
+	for _, line := range bytes.Split(bs, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.Split(string(line), "\x00")
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("unexpected branch line %q", line)
+		}
+		b := Branch{
+			Head:     fields[0] == "*",
+			Name:     fields[1],
+			Sha:      fields[2],
+			Upstream: fields[3],
+		}
+		if err := parseTrack(fields[4], &b); err != nil {
+			return nil, err
+		}
+		if len(fields[5]) > 0 {
+			secs, err := strconv.ParseInt(fields[5], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected commit date %q", fields[5])
+			}
+			b.CommitDate = time.Unix(secs, 0)
+		}
+		ret = append(ret, b)
+	}
+	return ret, nil
+}
+
+// Branches returns the local branches of repo.
+func (a *Client) Branches(repo string) ([]Branch, error) {
+	bs, err := a.output(repo, "for-each-ref", "--format="+branchFormat, "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	return parseBranches(bs)
+}
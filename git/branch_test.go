@@ -0,0 +1,35 @@
+package git
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBranches(t *testing.T) {
+	lines := []string{
+		"*\x00master\x00aaaa\x00origin/master\x00\x001500000000",
+		" \x00feature\x00bbbb\x00origin/feature\x00gone\x001500000001",
+		" \x00topic\x00cccc\x00origin/topic\x00ahead 1, behind 2\x001500000002",
+		" \x00local\x00dddd\x00\x00\x001500000003",
+	}
+	bs, err := parseBranches([]byte(strings.Join(lines, "\n") + "\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []Branch{
+		{Name: "master", Sha: "aaaa", Head: true, Upstream: "origin/master", CommitDate: time.Unix(1500000000, 0)},
+		{Name: "feature", Sha: "bbbb", Upstream: "origin/feature", Gone: true, CommitDate: time.Unix(1500000001, 0)},
+		{Name: "topic", Sha: "cccc", Upstream: "origin/topic", Ahead: 1, Behind: 2, CommitDate: time.Unix(1500000002, 0)},
+		{Name: "local", Sha: "dddd", CommitDate: time.Unix(1500000003, 0)},
+	}
+	if !reflect.DeepEqual(bs, expected) {
+		t.Errorf("expected %+v but found %+v", expected, bs)
+	}
+
+	if _, err := parseBranches([]byte(" \x00topic\x00cccc\x00origin/topic\x00sideways 1\x000\n")); err == nil {
+		t.Errorf("expected error")
+	}
+}
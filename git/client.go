@@ -1,5 +1,10 @@
 package git
 
+import (
+	"context"
+	"os/exec"
+)
+
 var defaultClientOpt = &ClientOpt{
 	GitPath: "git",
 }
@@ -7,11 +12,14 @@ var defaultClientOpt = &ClientOpt{
 // A Client represents a user of git.
 type Client struct {
 	gitPath string
+	ctx     context.Context
 }
 
 type ClientOpt struct {
 	// Path to git program
 	GitPath string
+	// If not nil, git processes are killed when the context is done
+	Context context.Context
 }
 
 // NewClient creates a new git client.
@@ -20,7 +28,27 @@ func NewClient(opt *ClientOpt) *Client {
 		opt = defaultClientOpt
 	}
 
-	return &Client{
+	ret := &Client{
 		gitPath: opt.GitPath,
+		ctx:     opt.Context,
+	}
+	if len(ret.gitPath) == 0 {
+		ret.gitPath = defaultClientOpt.GitPath
 	}
+	if ret.ctx == nil {
+		ret.ctx = context.Background()
+	}
+	return ret
+}
+
+// command returns the command to run git with args in dir.
+func (a *Client) command(dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(a.ctx, a.gitPath, args...)
+	cmd.Dir = dir
+	return cmd
+}
+
+// output runs git with args in dir and returns its stdout.
+func (a *Client) output(dir string, args ...string) ([]byte, error) {
+	return a.command(dir, args...).Output()
 }
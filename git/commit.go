@@ -3,38 +3,31 @@ package git
 import (
 	"bytes"
 	"fmt"
-	"os/exec"
 )
 
 // A Commit represents a git commit.
 type Commit struct {
-	Sha      string // Commit sha
-	Branch   string // Branch name
-	Upstream string // Upstream branch name
-	Repo     string
+	Sha      string `json:"sha"`      // Commit sha
+	Branch   string `json:"branch"`   // Branch name
+	Upstream string `json:"upstream"` // Upstream branch name
+	Repo     string `json:"repo"`
 	client   *Client
 }
 
-func output(dir, prog string, args ...string) ([]byte, error) {
-	cmd := exec.Command(prog, args...)
-	cmd.Dir = dir
-	return cmd.Output()
-}
-
 // Head returns the git HEAD commit
 func (a *Client) Head(repo string) (*Commit, error) {
-	branch, err := output(repo, a.gitPath, "rev-parse", "--abbrev-ref", "HEAD")
+	branch, err := a.output(repo, "rev-parse", "--abbrev-ref", "HEAD")
 	if err != nil {
 		return nil, err
 	}
 
 	branchStr := string(bytes.TrimSpace(branch))
-	sha, err := output(repo, a.gitPath, "rev-parse", branchStr)
+	sha, err := a.output(repo, "rev-parse", branchStr)
 	if err != nil {
 		return nil, err
 	}
 
-	upstream, _ := output(repo, a.gitPath, "rev-parse", "--abbrev-ref", fmt.Sprintf("%s@{upstream}", branchStr))
+	upstream, _ := a.output(repo, "rev-parse", "--abbrev-ref", fmt.Sprintf("%s@{upstream}", branchStr))
 
 	return &Commit{
 		Sha:      string(bytes.TrimSpace(sha)),
@@ -51,13 +44,13 @@ func (a *Commit) UpstreamMerge() (*Merge, error) {
 		return nil, fmt.Errorf("no upstream branch")
 	}
 
-	upstreamSha, err := output(a.Repo, a.client.gitPath, "rev-parse", a.Upstream)
+	upstreamSha, err := a.client.output(a.Repo, "rev-parse", a.Upstream)
 	if err != nil {
 		return nil, err
 	}
 
 	upstreamShaStr := string(bytes.TrimSpace(upstreamSha))
-	mergeSha, err := output(a.Repo, a.client.gitPath, "merge-base", a.Sha, upstreamShaStr)
+	mergeSha, err := a.client.output(a.Repo, "merge-base", a.Sha, upstreamShaStr)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,30 @@
+package git
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// ChangedSince returns true if HEAD differs from commit. If paths are given,
+// only changes to those paths are considered.
+func (a *Client) ChangedSince(repo, commit string, paths ...string) (bool, error) {
+	args := []string{"diff", "--quiet", commit, "HEAD", "--"}
+	args = append(args, paths...)
+	cmd := a.command(repo, args...)
+	err := cmd.Run()
+	if err == nil {
+		return false, nil
+	}
+	ee, ok := err.(*exec.ExitError)
+	if !ok {
+		return false, err
+	}
+	s, ok := ee.Sys().(syscall.WaitStatus)
+	if !ok {
+		return false, err
+	}
+	if s.ExitStatus() == 1 {
+		return true, nil
+	}
+	return false, err
+}
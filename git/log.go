@@ -10,15 +10,15 @@ import (
 
 // A Log is the data associated with a commit.
 type Log struct {
-	Commit        string
-	Tree          string
-	Parents       []string
-	Author        string
-	AuthorDate    time.Time
-	Committer     string
-	CommitterDate time.Time
-	Subject       string
-	Body          string
+	Commit        string    `json:"commit"`
+	Tree          string    `json:"tree"`
+	Parents       []string  `json:"parents"`
+	Author        string    `json:"author"`
+	AuthorDate    time.Time `json:"author_date"`
+	Committer     string    `json:"committer"`
+	CommitterDate time.Time `json:"committer_date"`
+	Subject       string    `json:"subject"`
+	Body          string    `json:"body"`
 }
 
 func mustConsume(bs []byte, target []byte) []byte {
@@ -180,7 +180,7 @@ func (a *Client) Logs(repo string, commits ...string) ([]Log, error) {
 	var args []string
 	args = append(args, "rev-list", "--header")
 	args = append(args, commits...)
-	out, err := output(repo, a.gitPath, args...)
+	out, err := a.output(repo, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -10,3 +10,9 @@ type Merge struct {
 func (a *Merge) CanFFMerge() bool {
 	return a.Topic.Sha != a.Current.Sha && a.Base.Sha == a.Current.Sha
 }
+
+// Has Topic already been merged into Current? i.e., is Current up to date
+// or ahead of Topic
+func (a *Merge) UpToDate() bool {
+	return a.Base.Sha == a.Topic.Sha
+}
@@ -0,0 +1,25 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+)
+
+// RemoteURL returns the url of the named remote.
+func (a *Client) RemoteURL(repo, remote string) (string, error) {
+	url, err := a.output(repo, "config", "--get", "remote."+remote+".url")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(url)), nil
+}
+
+// DefaultBranch returns the default branch of the named remote as recorded by
+// refs/remotes/<remote>/HEAD (e.g., "main").
+func (a *Client) DefaultBranch(repo, remote string) (string, error) {
+	ref, err := a.output(repo, "symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(string(bytes.TrimSpace(ref)), remote+"/"), nil
+}
@@ -0,0 +1,91 @@
+package git
+
+import (
+	"bytes"
+)
+
+// allCherryPicked returns true if every commit in the output of git cherry is
+// marked as having an equivalent upstream commit.
+func allCherryPicked(bs []byte) bool {
+	var n int
+	for _, line := range bytes.Split(bs, []byte{'\n'}) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '-' {
+			return false
+		}
+		n += 1
+	}
+	return n > 0
+}
+
+// parsePatchIDs returns the patch ids in the output of git patch-id.
+func parsePatchIDs(bs []byte) []string {
+	var ret []string
+	for _, line := range bytes.Split(bs, []byte{'\n'}) {
+		fields := bytes.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		ret = append(ret, string(fields[0]))
+	}
+	return ret
+}
+
+// patchIDs returns the stable patch ids of patches.
+func (a *Client) patchIDs(repo string, patches []byte) ([]string, error) {
+	cmd := a.command(repo, "patch-id", "--stable")
+	cmd.Stdin = bytes.NewReader(patches)
+	bs, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePatchIDs(bs), nil
+}
+
+// SquashMerged returns true if the changes of branch are already in into even
+// though branch is not an ancestor of into, e.g., because branch was
+// squash-merged or rebased. This is the case if every commit of branch has an
+// equivalent commit in into (see git cherry) or if the combined diff of branch
+// has the same patch id as a commit in into.
+func (a *Client) SquashMerged(repo, branch, into string) (bool, error) {
+	cherry, err := a.output(repo, "cherry", into, branch)
+	if err != nil {
+		return false, err
+	}
+	if allCherryPicked(cherry) {
+		return true, nil
+	}
+
+	base, err := a.output(repo, "merge-base", into, branch)
+	if err != nil {
+		return false, err
+	}
+	baseStr := string(bytes.TrimSpace(base))
+
+	diff, err := a.output(repo, "diff", "--no-ext-diff", "--full-index", baseStr, branch)
+	if err != nil {
+		return false, err
+	}
+	ids, err := a.patchIDs(repo, diff)
+	if err != nil || len(ids) == 0 {
+		return false, err
+	}
+
+	patches, err := a.output(repo, "log", "-p", "--no-ext-diff", "--full-index", "--no-merges", into, RevListNot(baseStr))
+	if err != nil {
+		return false, err
+	}
+	intoIDs, err := a.patchIDs(repo, patches)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range intoIDs {
+		if id == ids[0] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,106 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAllCherryPicked(t *testing.T) {
+	for _, c := range []struct {
+		out      string
+		expected bool
+	}{
+		{"", false},
+		{"- aaaa\n- bbbb\n", true},
+		{"- aaaa\n+ bbbb\n", false},
+		{"+ aaaa\n", false},
+	} {
+		if found := allCherryPicked([]byte(c.out)); found != c.expected {
+			t.Errorf("%q: expected %v but found %v", c.out, c.expected, found)
+		}
+	}
+}
+
+func TestParsePatchIDs(t *testing.T) {
+	expected := []string{"1111", "2222"}
+	found := parsePatchIDs([]byte("1111 aaaa\n2222 bbbb\n"))
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v but found %v", expected, found)
+	}
+}
+
+// runGit runs git in dir with a fixed identity and fails t on error.
+func runGit(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=peanut", "GIT_AUTHOR_EMAIL=peanut@example.com",
+		"GIT_COMMITTER_NAME=peanut", "GIT_COMMITTER_EMAIL=peanut@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %s: %s", args, err, out)
+	}
+}
+
+func TestSquashMerged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peanut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runGit(t, dir, "init", "-q", "-b", "main")
+	write("a", "a\n")
+	runGit(t, dir, "add", "a")
+	runGit(t, dir, "commit", "-q", "-m", "a")
+
+	// Two commits squash-merged into main
+	runGit(t, dir, "checkout", "-q", "-b", "squashed")
+	write("b", "b\n")
+	runGit(t, dir, "add", "b")
+	runGit(t, dir, "commit", "-q", "-m", "b1")
+	write("b", "b\nbb\n")
+	runGit(t, dir, "commit", "-q", "-a", "-m", "b2")
+	runGit(t, dir, "checkout", "-q", "main")
+	runGit(t, dir, "merge", "-q", "--squash", "squashed")
+	runGit(t, dir, "commit", "-q", "-m", "squash")
+
+	// Work not in main
+	runGit(t, dir, "checkout", "-q", "-b", "unmerged")
+	write("c", "c\n")
+	runGit(t, dir, "add", "c")
+	runGit(t, dir, "commit", "-q", "-m", "c")
+	runGit(t, dir, "checkout", "-q", "main")
+
+	client := NewClient(nil)
+	for branch, expected := range map[string]bool{"squashed": true, "unmerged": false} {
+		found, err := client.SquashMerged(dir, branch, "main")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", branch, err)
+		}
+		if found != expected {
+			t.Errorf("%s: expected %v but found %v", branch, expected, found)
+		}
+	}
+
+	wt, err := client.WorkTree(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmerged, err := wt.UnmergedBranches("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"unmerged"}; !reflect.DeepEqual(unmerged, expected) {
+		t.Errorf("expected %v but found %v", expected, unmerged)
+	}
+}
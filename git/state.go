@@ -0,0 +1,79 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// A StateKind classifies a working tree relative to its upstream branch.
+type StateKind string
+
+const (
+	StateClean      StateKind = "clean"              // Up to date with upstream and no changes
+	StateDirty      StateKind = "dirty"              // Up to date with upstream but with changes
+	StateAhead      StateKind = "ahead"              // Upstream is an ancestor of HEAD
+	StateBehind     StateKind = "behind"             // HEAD is an ancestor of upstream
+	StateDiverged   StateKind = "diverged"           // HEAD and upstream both have new commits
+	StateDetached   StateKind = "detached"           // HEAD is not a branch
+	StateNoUpstream StateKind = "no-upstream"        // Branch has no upstream
+	StateGone       StateKind = "gone-upstream"      // Upstream branch no longer exists
+	StateRebasing   StateKind = "rebase-in-progress" // Rebase has been started but not finished
+	StateMerging    StateKind = "merge-in-progress"  // Merge has been started but not finished
+)
+
+// A State is the state of a working tree relative to its upstream branch.
+type State struct {
+	Kind   StateKind `json:"kind"`
+	Ahead  int       `json:"ahead"`  // Commits in HEAD but not upstream
+	Behind int       `json:"behind"` // Commits in upstream but not HEAD
+}
+
+func (a State) String() string {
+	switch a.Kind {
+	case StateAhead:
+		return fmt.Sprintf("%s %d", a.Kind, a.Ahead)
+	case StateBehind:
+		return fmt.Sprintf("%s %d", a.Kind, a.Behind)
+	case StateDiverged:
+		return fmt.Sprintf("%s %d/%d", a.Kind, a.Ahead, a.Behind)
+	default:
+		return string(a.Kind)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Classify porcelain status; gitDir is used to detect operations in progress
+func newState(ps *porcelainStatus, gitDir string) State {
+	s := State{
+		Ahead:  ps.ahead,
+		Behind: ps.behind,
+	}
+	switch {
+	case exists(filepath.Join(gitDir, "rebase-merge")) || exists(filepath.Join(gitDir, "rebase-apply")):
+		s.Kind = StateRebasing
+	case exists(filepath.Join(gitDir, "MERGE_HEAD")):
+		s.Kind = StateMerging
+	case ps.head == "(detached)":
+		s.Kind = StateDetached
+	case len(ps.upstream) == 0:
+		s.Kind = StateNoUpstream
+	case !ps.hasAB:
+		s.Kind = StateGone
+	case ps.ahead > 0 && ps.behind > 0:
+		s.Kind = StateDiverged
+	case ps.ahead > 0:
+		s.Kind = StateAhead
+	case ps.behind > 0:
+		s.Kind = StateBehind
+	case len(ps.files) > 0:
+		s.Kind = StateDirty
+	default:
+		s.Kind = StateClean
+	}
+	return s
+}
@@ -2,37 +2,190 @@ package git
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 )
 
+// A FileKind is the kind of change recorded for a file in the working tree.
+type FileKind string
+
+const (
+	Changed   FileKind = "changed"   // Ordinary change
+	Renamed   FileKind = "renamed"   // Renamed from OrigPath
+	Copied    FileKind = "copied"    // Copied from OrigPath
+	Unmerged  FileKind = "unmerged"  // Merge conflict
+	Untracked FileKind = "untracked" // Not tracked by git
+)
+
+// A File is a file with changes in the working tree.
+type File struct {
+	Path     string   `json:"path"`
+	OrigPath string   `json:"orig_path,omitempty"` // Original path of a renamed or copied file
+	Kind     FileKind `json:"kind"`                // Kind of change
+	XY       string   `json:"xy"`                  // Index (X) and working tree (Y) status; "." is unmodified
+}
+
+// Staged returns true if the file has changes in the index.
+func (a File) Staged() bool {
+	return a.Kind != Untracked && a.Kind != Unmerged && len(a.XY) == 2 && a.XY[0] != '.'
+}
+
+// Unstaged returns true if the file has changes in the working tree that are
+// not in the index.
+func (a File) Unstaged() bool {
+	return a.Kind != Untracked && a.Kind != Unmerged && len(a.XY) == 2 && a.XY[1] != '.'
+}
+
+// Conflicted returns true if the file has unresolved merge conflicts.
+func (a File) Conflicted() bool {
+	return a.Kind == Unmerged
+}
+
+// Untracked returns true if the file is not tracked by git.
+func (a File) Untracked() bool {
+	return a.Kind == Untracked
+}
+
+// String returns the path of the file, including the original path if it was
+// renamed or copied.
+func (a File) String() string {
+	if len(a.OrigPath) > 0 {
+		return fmt.Sprintf("%s -> %s", a.OrigPath, a.Path)
+	}
+	return a.Path
+}
+
 // A WorkTree represents the working directory in git.
 type WorkTree struct {
 	Commit     *Commit
-	DirtyFiles []string
+	Files      []File   // Files with changes
+	DirtyFiles []string // Paths of Files
+	Ahead      int      // Commits in HEAD but not upstream
+	Behind     int      // Commits in upstream but not HEAD
+	State      State    // State relative to upstream
 	Repo       string
+	GitDir     string // Path to .git directory
 	client     *Client
 }
 
+type porcelainStatus struct {
+	files    []File
+	head     string // Branch name or "(detached)"
+	upstream string
+	hasAB    bool // Upstream exists and ahead/behind are valid
+	ahead    int
+	behind   int
+}
+
+// Parse output of git status --porcelain=v2 -z --branch
+func parsePorcelainV2(bs []byte) (*porcelainStatus, error) {
+	ret := &porcelainStatus{}
+	fields := bytes.Split(bs, []byte{'\x00'})
+	for idx := 0; idx < len(fields); idx += 1 {
+		line := string(fields[idx])
+		if len(line) == 0 {
+			continue
+		}
+		switch line[0] {
+		case '#':
+			parts := strings.SplitN(line, " ", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			switch parts[1] {
+			case "branch.head":
+				ret.head = parts[2]
+			case "branch.upstream":
+				ret.upstream = parts[2]
+			case "branch.ab":
+				if _, err := fmt.Sscanf(parts[2], "+%d -%d", &ret.ahead, &ret.behind); err != nil {
+					return nil, fmt.Errorf("could not parse %q: %s", line, err)
+				}
+				ret.hasAB = true
+			}
+		case '1':
+			// 1 <XY> <sub> <mH> <mI> <mW> <hH> <hI> <path>
+			parts := strings.SplitN(line, " ", 9)
+			if len(parts) != 9 {
+				return nil, fmt.Errorf("could not parse %q", line)
+			}
+			ret.files = append(ret.files, File{
+				Path: parts[8],
+				Kind: Changed,
+				XY:   parts[1],
+			})
+		case '2':
+			// 2 <XY> <sub> <mH> <mI> <mW> <hH> <hI> <X><score> <path>\0<origPath>
+			parts := strings.SplitN(line, " ", 10)
+			if len(parts) != 10 || idx+1 >= len(fields) {
+				return nil, fmt.Errorf("could not parse %q", line)
+			}
+			idx += 1
+			kind := Renamed
+			if strings.HasPrefix(parts[8], "C") {
+				kind = Copied
+			}
+			ret.files = append(ret.files, File{
+				Path:     parts[9],
+				OrigPath: string(fields[idx]),
+				Kind:     kind,
+				XY:       parts[1],
+			})
+		case 'u':
+			// u <XY> <sub> <m1> <m2> <m3> <mW> <h1> <h2> <h3> <path>
+			parts := strings.SplitN(line, " ", 11)
+			if len(parts) != 11 {
+				return nil, fmt.Errorf("could not parse %q", line)
+			}
+			ret.files = append(ret.files, File{
+				Path: parts[10],
+				Kind: Unmerged,
+				XY:   parts[1],
+			})
+		case '?':
+			ret.files = append(ret.files, File{
+				Path: strings.TrimPrefix(line, "? "),
+				Kind: Untracked,
+				XY:   "??",
+			})
+		case '!':
+			// Ignored
+		default:
+			return nil, fmt.Errorf("could not parse %q", line)
+		}
+	}
+	return ret, nil
+}
+
+// TopLevel returns the top level directory of the work tree containing dir.
+func (a *Client) TopLevel(dir string) (string, error) {
+	out, err := a.output(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
 // WorkTree returns the WorkTree for the given directory.
 func (a *Client) WorkTree(dir string) (*WorkTree, error) {
-	repo, err := output(dir, a.gitPath, "rev-parse", "--show-toplevel")
+	out, err := a.output(dir, "rev-parse", "--show-toplevel", "--absolute-git-dir")
 	if err != nil {
 		return nil, err
 	}
-	repoStr := string(bytes.TrimSpace(repo))
-
-	dirty, err := output(repoStr, a.gitPath, "ls-files",
-		"--exclude-standard",
-		"--others",
-		"--deleted",
-		"--modified",
-		"--directory",
-		"--no-empty-directory",
-		"-z")
+	lines := strings.Split(string(bytes.TrimSpace(out)), "\n")
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("could not parse %q", out)
+	}
+	repoStr, gitDir := lines[0], lines[1]
+
+	out, err = a.output(repoStr, "status", "--porcelain=v2", "-z", "--branch")
+	if err != nil {
+		return nil, err
+	}
+	ps, err := parsePorcelainV2(out)
 	if err != nil {
 		return nil, err
 	}
-	dirtyFiles := bytes.Split(dirty, []byte{'\x00'})
 
 	commit, err := a.Head(repoStr)
 	if err != nil {
@@ -41,21 +194,25 @@ func (a *Client) WorkTree(dir string) (*WorkTree, error) {
 
 	wt := &WorkTree{
 		Commit: commit,
+		Files:  ps.files,
+		Ahead:  ps.ahead,
+		Behind: ps.behind,
+		State:  newState(ps, gitDir),
 		Repo:   repoStr,
+		GitDir: gitDir,
 		client: a,
 	}
-	for _, name := range dirtyFiles {
-		if len(name) == 0 {
-			continue
-		}
-		wt.DirtyFiles = append(wt.DirtyFiles, string(name))
+	for _, f := range wt.Files {
+		wt.DirtyFiles = append(wt.DirtyFiles, f.Path)
 	}
 	return wt, nil
 }
 
-// UnmergedBranches returns branches that are not merged in branch.
+// UnmergedBranches returns branches that are not merged in branch. Branches
+// whose changes are in branch without being merged (see SquashMerged) are
+// considered merged.
 func (a *WorkTree) UnmergedBranches(branch string) ([]string, error) {
-	branches, err := output(a.Repo, a.client.gitPath, "branch", "--no-merged", branch)
+	branches, err := a.client.output(a.Repo, "branch", "--no-merged", branch)
 	if err != nil {
 		return nil, err
 	}
@@ -68,6 +225,10 @@ func (a *WorkTree) UnmergedBranches(branch string) ([]string, error) {
 		if strings.HasPrefix(s, "*") {
 			continue
 		}
+		// If squash detection fails, report the branch as unmerged
+		if squashed, err := a.client.SquashMerged(a.Repo, s, branch); err == nil && squashed {
+			continue
+		}
 		ret = append(ret, s)
 	}
 	return ret, nil
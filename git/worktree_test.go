@@ -0,0 +1,81 @@
+package git
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePorcelainV2(t *testing.T) {
+	lines := []string{
+		"# branch.oid 198a74da2f07a7f03e499c85109f90920b57aa45",
+		"# branch.head master",
+		"# branch.upstream origin/master",
+		"# branch.ab +1 -2",
+		"1 .M N... 100644 100644 100644 6178079822 6178079822 b",
+		"1 M. N... 100644 100644 100644 6178079822 6178079823 with space",
+		"2 R. N... 100644 100644 100644 7898192261 7898192261 R100 c",
+		"a",
+		"u UU N... 100644 100644 100644 100644 1111111111 2222222222 3333333333 conflict",
+		"? d/",
+		"! ignored",
+	}
+	ps, err := parsePorcelainV2([]byte(strings.Join(lines, "\x00") + "\x00"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ps.head != "master" || ps.upstream != "origin/master" || !ps.hasAB {
+		t.Errorf("unexpected branch headers %+v", ps)
+	}
+	if ps.ahead != 1 || ps.behind != 2 {
+		t.Errorf("expected +1 -2 but found +%d -%d", ps.ahead, ps.behind)
+	}
+
+	expected := []File{
+		{Path: "b", Kind: Changed, XY: ".M"},
+		{Path: "with space", Kind: Changed, XY: "M."},
+		{Path: "c", OrigPath: "a", Kind: Renamed, XY: "R."},
+		{Path: "conflict", Kind: Unmerged, XY: "UU"},
+		{Path: "d/", Kind: Untracked, XY: "??"},
+	}
+	if !reflect.DeepEqual(ps.files, expected) {
+		t.Errorf("expected %+v but found %+v", expected, ps.files)
+	}
+
+	if f := ps.files[0]; f.Staged() || !f.Unstaged() {
+		t.Errorf("expected %+v to be unstaged only", f)
+	}
+	if f := ps.files[2]; !f.Staged() || f.Unstaged() {
+		t.Errorf("expected %+v to be staged only", f)
+	}
+	if f := ps.files[3]; !f.Conflicted() || f.Staged() || f.Unstaged() {
+		t.Errorf("expected %+v to be conflicted only", f)
+	}
+}
+
+func TestParsePorcelainV2Error(t *testing.T) {
+	if _, err := parsePorcelainV2([]byte("1 .M truncated\x00")); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestState(t *testing.T) {
+	dir := t.TempDir()
+	for _, c := range []struct {
+		ps       porcelainStatus
+		expected string
+	}{
+		{porcelainStatus{head: "master", upstream: "origin/master", hasAB: true}, "clean"},
+		{porcelainStatus{head: "master", upstream: "origin/master", hasAB: true, files: []File{{}}}, "dirty"},
+		{porcelainStatus{head: "master", upstream: "origin/master", hasAB: true, ahead: 2}, "ahead 2"},
+		{porcelainStatus{head: "master", upstream: "origin/master", hasAB: true, behind: 3}, "behind 3"},
+		{porcelainStatus{head: "master", upstream: "origin/master", hasAB: true, ahead: 2, behind: 3}, "diverged 2/3"},
+		{porcelainStatus{head: "(detached)"}, "detached"},
+		{porcelainStatus{head: "master"}, "no-upstream"},
+		{porcelainStatus{head: "master", upstream: "origin/master"}, "gone-upstream"},
+	} {
+		if s := newState(&c.ps, dir).String(); s != c.expected {
+			t.Errorf("expected %q but found %q for %+v", c.expected, s, c.ps)
+		}
+	}
+}
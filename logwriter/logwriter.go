@@ -70,6 +70,7 @@ func (a *LogWriter) Flush() error {
 	if len(b) > 0 {
 		a.println(b)
 	}
+	a.buf.Reset()
 	return nil
 }
 
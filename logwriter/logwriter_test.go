@@ -84,3 +84,22 @@ func TestMultipleWritesMultipleNewlines(t *testing.T) {
 		t.Errorf("expected %q but found %q", expected.String(), buf.String())
 	}
 }
+
+func TestFlushTwice(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	lw := New(logger, nil)
+	towrite := "without newline"
+	expected := towrite + "\n"
+	if _, err := lw.Write([]byte(towrite)); err != nil {
+		t.Fatalf("error writing: %s", err)
+	}
+	for idx := 0; idx < 2; idx++ {
+		if err := lw.Flush(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if buf.String() != expected {
+		t.Errorf("expected %q but found %q", expected, buf.String())
+	}
+}
@@ -3,6 +3,8 @@ package pdo
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,11 +14,35 @@ type Func func(context.Context, interface{}) error
 type GenFunc func(context.Context, interface{}) ([]interface{}, error)
 
 type DoAllOpt struct {
-	GenFunc       GenFunc       // If not nil, make a two-stage pipeline Func(GenFunc(Item))
-	Func          Func          // Func(Item)
-	Items         []interface{} // Iteration space
-	Timeout       time.Duration // Maximum duration of any function
-	MaxConcurrent int           // Maximum number of concurrent threads per stage minus 1
+	GenFunc         GenFunc         // If not nil, make a two-stage pipeline Func(GenFunc(Item))
+	Func            Func            // Func(Item)
+	Items           []interface{}   // Iteration space
+	Timeout         time.Duration   // Maximum duration of any function
+	MaxConcurrent   int             // Maximum number of concurrent threads per stage minus 1
+	ContinueOnError bool            // If true, process all items and return Errors rather than the first error
+	FailFast        bool            // If true, cancel outstanding functions and stop processing items on the first error
+	Context         context.Context // If not nil, the parent context of all functions
+}
+
+// An ItemError is an error returned by Func or GenFunc for Item.
+type ItemError struct {
+	Item interface{}
+	Err  error
+}
+
+func (a ItemError) Error() string {
+	return fmt.Sprintf("%v: %s", a.Item, a.Err)
+}
+
+// Errors is returned by DoAll if ContinueOnError is set and any item failed.
+type Errors []ItemError
+
+func (a Errors) Error() string {
+	var msgs []string
+	for _, e := range a {
+		msgs = append(msgs, e.Error())
+	}
+	return fmt.Sprintf("%d errors: %s", len(a), strings.Join(msgs, "; "))
 }
 
 func DoAll(opt DoAllOpt) error {
@@ -37,13 +63,17 @@ func DoAll(opt DoAllOpt) error {
 		return opt.GenFunc(ctx, item)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	pctx := opt.Context
+	if pctx == nil {
+		pctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(pctx)
 	defer cancel()
 
 	var next chan interface{}
 	items := make(chan interface{})
 	done := make(chan bool)
-	errs := make(chan error)
+	errs := make(chan ItemError)
 	defer close(done)
 
 	// Distributor
@@ -75,21 +105,25 @@ func DoAll(opt DoAllOpt) error {
 			go func() {
 				defer errsWg.Done()
 				defer wg.Done()
-				for {
-					select {
-					case <-done:
+				for item := range items {
+					// Report rather than start items once cancelled
+					if err := ctx.Err(); err != nil {
+						errs <- ItemError{Item: item, Err: err}
+						continue
+					}
+					ns, err := doGen(ctx, item)
+					errs <- ItemError{Item: item, Err: err}
+					if err != nil && opt.FailFast {
+						cancel()
+					}
+					if err != nil && !opt.ContinueOnError {
 						return
-					case item := <-items:
-						if item == nil {
-							return
-						}
-						ns, err := doGen(ctx, item)
-						errs <- err
-						if err != nil {
-							return
-						}
-						for _, n := range ns {
-							next <- n
+					}
+					for _, n := range ns {
+						select {
+						case next <- n:
+						case <-ctx.Done():
+							errs <- ItemError{Item: n, Err: ctx.Err()}
 						}
 					}
 				}
@@ -108,19 +142,19 @@ func DoAll(opt DoAllOpt) error {
 	for idx := 0; idx < num; idx += 1 {
 		go func() {
 			defer errsWg.Done()
-			for {
-				select {
-				case <-done:
+			for item := range next {
+				// Report rather than start items once cancelled
+				if err := ctx.Err(); err != nil {
+					errs <- ItemError{Item: item, Err: err}
+					continue
+				}
+				err := doOne(ctx, item)
+				errs <- ItemError{Item: item, Err: err}
+				if err != nil && opt.FailFast {
+					cancel()
+				}
+				if err != nil && !opt.ContinueOnError {
 					return
-				case item := <-next:
-					if item == nil {
-						return
-					}
-					err := doOne(ctx, item)
-					errs <- err
-					if err != nil {
-						return
-					}
 				}
 			}
 		}()
@@ -134,10 +168,18 @@ func DoAll(opt DoAllOpt) error {
 
 	// Collect results
 	var err error
+	var all Errors
 	for e := range errs {
-		if e != nil && err == nil {
-			err = e
+		if e.Err == nil {
+			continue
 		}
+		if err == nil {
+			err = e.Err
+		}
+		all = append(all, e)
+	}
+	if opt.ContinueOnError && len(all) > 0 {
+		return all
 	}
 	return err
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestUnique(t *testing.T) {
@@ -91,6 +92,141 @@ func TestError(t *testing.T) {
 		},
 		Items: items,
 	}); err != bad {
-		t.Error("expecting error %v but found %v", bad, err)
+		t.Errorf("expecting error %v but found %v", bad, err)
+	}
+}
+
+func TestContinueOnError(t *testing.T) {
+	N := 100
+	var lock sync.Mutex
+	var count int
+
+	bad := fmt.Errorf("bad")
+	var items []interface{}
+	for i := 0; i < N; i += 1 {
+		items = append(items, i)
+	}
+	err := DoAll(DoAllOpt{
+		Func: func(ctx context.Context, item interface{}) error {
+			lock.Lock()
+			defer lock.Unlock()
+			count += 1
+			if item.(int)%10 == 0 {
+				return bad
+			}
+			return nil
+		},
+		Items:           items,
+		ContinueOnError: true,
+	})
+
+	if count != N {
+		t.Errorf("%d != %d", count, N)
+	}
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expecting Errors but found %v", err)
+	}
+	if len(errs) != N/10 {
+		t.Errorf("expecting %d errors but found %d", N/10, len(errs))
+	}
+	for _, e := range errs {
+		if e.Item.(int)%10 != 0 || e.Err != bad {
+			t.Errorf("unexpected error %v", e)
+		}
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	N := 100
+	bad := fmt.Errorf("bad")
+	var items []interface{}
+	for i := 0; i < N; i += 1 {
+		items = append(items, i)
+	}
+
+	var lock sync.Mutex
+	var count int
+	err := DoAll(DoAllOpt{
+		Func: func(ctx context.Context, item interface{}) error {
+			lock.Lock()
+			count += 1
+			lock.Unlock()
+			if item.(int) == 0 {
+				return bad
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Items:           items,
+		MaxConcurrent:   3,
+		Timeout:         time.Minute,
+		ContinueOnError: true,
+		FailFast:        true,
+	})
+
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expecting Errors but found %v", err)
+	}
+	if count == N || len(errs) != N {
+		t.Errorf("expecting %d errors with %d items started but found %d errors and %d started", N, count, len(errs), count)
+	}
+	for _, e := range errs {
+		if e.Item.(int) == 0 && e.Err != bad {
+			t.Errorf("unexpected error %v", e)
+		} else if e.Item.(int) != 0 && e.Err != context.Canceled {
+			t.Errorf("unexpected error %v", e)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var count int
+	err := DoAll(DoAllOpt{
+		Func: func(ctx context.Context, item interface{}) error {
+			count += 1
+			return nil
+		},
+		Items:   []interface{}{1, 2, 3},
+		Context: ctx,
+	})
+	if err != context.Canceled {
+		t.Errorf("expecting %v but found %v", context.Canceled, err)
+	}
+	if count != 0 {
+		t.Errorf("expecting no items started but found %d", count)
+	}
+}
+
+func TestContextWithGen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var lock sync.Mutex
+	var count int
+	err := DoAll(DoAllOpt{
+		GenFunc: func(ctx context.Context, item interface{}) ([]interface{}, error) {
+			if item.(int) == 0 {
+				cancel()
+			}
+			return []interface{}{item}, nil
+		},
+		Func: func(ctx context.Context, item interface{}) error {
+			lock.Lock()
+			defer lock.Unlock()
+			count += 1
+			return nil
+		},
+		Items:           []interface{}{0, 1, 2, 3},
+		ContinueOnError: true,
+		Context:         ctx,
+	})
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expecting Errors but found %v", err)
+	}
+	if count+len(errs) != 4 {
+		t.Errorf("expecting every item to run or fail but found %d run and %d errors", count, len(errs))
 	}
 }
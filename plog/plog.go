@@ -1,6 +1,7 @@
 package plog
 
 import (
+	"io"
 	"log"
 	"os"
 
@@ -8,7 +9,7 @@ import (
 )
 
 var (
-	Out = os.Stdout
+	Out io.Writer = os.Stdout
 )
 
 func New() *log.Logger {